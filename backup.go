@@ -0,0 +1,199 @@
+package drydock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBackupDir is the backup directory used when an empty dir is passed to [WithBackup].
+const DefaultBackupDir = ".drydock/backups"
+
+// DefaultBackupRetention is the number of backups kept by default, see [WithBackupRetention].
+const DefaultBackupRetention = 10
+
+const backupTimeFormat = "2006-01-02T15-04-05"
+
+var ErrBackup = errors.New("backup error")
+
+// WithBackup copies every existing file into a timestamped backup tree in dir, e.g. `.drydock/backups/2026-10-17T12-00-00/`,
+// before it is overwritten, modified or removed by [WithEmptyOutputDir]. Files whose contents don't change are not copied.
+// The backup of the last run can be restored using [RestoreBackup]. The dir is relative to the output root and is never removed by [WithEmptyOutputDir] itself.
+func WithBackup(dir string) Option {
+	return func(g *Generator) {
+		if dir == "" {
+			dir = DefaultBackupDir
+		}
+
+		g.backupDir = path.Clean(dir)
+	}
+}
+
+// WithBackupRetention sets the number of backups kept in the backup dir, older ones are removed automatically.
+// A value of 0 or less keeps all backups.
+func WithBackupRetention(n int) Option {
+	return func(g *Generator) {
+		g.backupRetention = n
+	}
+}
+
+// BackupID returns the ID of the backup made by the last call to [Generator.Generate],
+// or an empty string if no backup was made.
+func (g *Generator) BackupID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.backupID
+}
+
+// ListBackups returns the IDs of all backups in dir, oldest first.
+func ListBackups(output OutputFS, dir string) ([]string, error) {
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+
+	entries, err := fs.ReadDir(output, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%w: error listing backups in '%s': %w", ErrBackup, dir, err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, path.Join(dir, e.Name()))
+		}
+	}
+
+	slices.SortFunc(ids, compareBackupIDs)
+
+	return ids, nil
+}
+
+// compareBackupIDs orders backups by their timestamp and then by the counter
+// that is appended when multiple backups are made within the same second.
+func compareBackupIDs(a, b string) int {
+	a, b = path.Base(a), path.Base(b)
+	if len(a) < len(backupTimeFormat) || len(b) < len(backupTimeFormat) {
+		return strings.Compare(a, b)
+	}
+
+	if c := strings.Compare(a[:len(backupTimeFormat)], b[:len(backupTimeFormat)]); c != 0 {
+		return c
+	}
+
+	return backupCounter(a) - backupCounter(b)
+}
+
+func backupCounter(id string) int {
+	id = path.Base(id)
+	if len(id) <= len(backupTimeFormat) {
+		return 0
+	}
+
+	counter, _ := strconv.Atoi(strings.TrimPrefix(id[len(backupTimeFormat):], "-"))
+
+	return counter
+}
+
+// RestoreBackup copies all files in the backup with the given ID back to their original location in the output.
+// The ID is the path of the backup relative to the output root, as returned by [Generator.BackupID] and [ListBackups].
+func RestoreBackup(output OutputFS, id string) error {
+	return fs.WalkDir(output, id, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("%w: error restoring backup '%s': %w", ErrBackup, id, err)
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		target := strings.TrimPrefix(p, id+"/")
+
+		err = copyFile(output, p, target)
+		if err != nil {
+			return fmt.Errorf("%w: error restoring '%s' from backup '%s': %w", ErrBackup, target, id, err)
+		}
+
+		return nil
+	})
+}
+
+// backupExisting copies the files from the output to the backup of the run and removes backups exceeding
+// the retention limit. The backup is created by the first call with files, later calls add to it.
+func (r *run) backupExisting(files []string) error {
+	if r.backupDir == "" || len(files) == 0 {
+		return nil
+	}
+
+	id := r.backupID
+	if id == "" {
+		var err error
+		id, err = r.newBackupID()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		err := copyFile(r.output, file, path.Join(id, file))
+		if err != nil {
+			return fmt.Errorf("%w: error backing up file '%s': %w", ErrBackup, file, err)
+		}
+	}
+
+	if r.backupID != "" {
+		return nil
+	}
+
+	r.backupID = id
+
+	return r.pruneBackups()
+}
+
+// newBackupID returns a new ID based on the current time. Backups made within the same second get
+// an increasing counter appended, even if earlier backups of that second have been pruned already.
+func (r *run) newBackupID() (string, error) {
+	id := path.Join(r.backupDir, time.Now().UTC().Format(backupTimeFormat))
+
+	existing, err := ListBackups(r.output, r.backupDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(existing) == 0 || compareBackupIDs(existing[len(existing)-1], id) < 0 {
+		return id, nil
+	}
+
+	return fmt.Sprintf("%s-%d", id, backupCounter(existing[len(existing)-1])+1), nil
+}
+
+func (r *run) pruneBackups() error {
+	if r.backupRetention <= 0 {
+		return nil
+	}
+
+	ids, err := ListBackups(r.output, r.backupDir)
+	if err != nil {
+		return err
+	}
+
+	for len(ids) > r.backupRetention {
+		err = r.output.RemoveAll(ids[0])
+		if err != nil {
+			return fmt.Errorf("%w: error removing old backup '%s': %w", ErrBackup, ids[0], err)
+		}
+
+		ids = ids[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package drydock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Backup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md":      &fstest.MapFile{Data: []byte("old readme")},
+		"pkg/config.ini": &fstest.MapFile{Data: []byte("foo = bar")},
+		"LICENSE":        &fstest.MapFile{Data: []byte("old license")},
+		"same.txt":       &fstest.MapFile{Data: []byte("same")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithBackup(""))
+	err := g.Generate(ctx,
+		PlainFile("README.md", "new readme"),
+		PlainFile("main.go", "package main"),
+		PlainFile("same.txt", "same"),
+		OnConflict(PlainFile("LICENSE", "new license"), ConflictSkip),
+		Dir("pkg", ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(append(contents, []byte("\nbaz = bat")...))
+			return err
+		})),
+	)
+	require.NoError(t, err)
+
+	id := g.BackupID()
+	require.NotEmpty(t, id)
+	assert.True(t, strings.HasPrefix(id, DefaultBackupDir+"/"))
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS[path.Join(id, "README.md")].Data))
+	assert.Equal(t, "foo = bar", string(tmpfs.MapFS[path.Join(id, "pkg/config.ini")].Data))
+	assert.NotContains(t, tmpfs.MapFS, path.Join(id, "LICENSE"))
+	assert.NotContains(t, tmpfs.MapFS, path.Join(id, "main.go"))
+	assert.NotContains(t, tmpfs.MapFS, path.Join(id, "same.txt"))
+
+	assert.Equal(t, "new readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "foo = bar\nbaz = bat", string(tmpfs.MapFS["pkg/config.ini"].Data))
+
+	err = RestoreBackup(tmpfs, id)
+	require.NoError(t, err)
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "foo = bar", string(tmpfs.MapFS["pkg/config.ini"].Data))
+}
+
+func TestGenerator_Generate_Backup_Retention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md": &fstest.MapFile{Data: []byte("readme 0")},
+	}, baseDir: "."}
+
+	ids := make([]string, 0, 5)
+	for i := 1; i <= 5; i++ {
+		g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithBackup("backups"), WithBackupRetention(3))
+		err := g.Generate(ctx, PlainFile("README.md", fmt.Sprintf("readme %d", i)))
+		require.NoError(t, err)
+		ids = append(ids, g.BackupID())
+	}
+
+	backups, err := ListBackups(tmpfs, "backups")
+	require.NoError(t, err)
+	assert.Equal(t, ids[2:], backups)
+
+	assert.Equal(t, "readme 4", string(tmpfs.MapFS[path.Join(ids[4], "README.md")].Data))
+}
+
+func TestGenerator_Generate_Backup_KeptOnEmptyOutputDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md": &fstest.MapFile{Data: []byte("old readme")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithBackup(""))
+	err := g.Generate(ctx, PlainFile("README.md", "new readme"))
+	require.NoError(t, err)
+
+	g = NewGenerator(tmpfs, WithEmptyOutputDir(true), WithBackup(""))
+	err = g.Generate(ctx, PlainFile("main.go", "package main"))
+	require.NoError(t, err)
+
+	backups, err := ListBackups(tmpfs, "")
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+	assert.NotContains(t, tmpfs.MapFS, "README.md")
+}
+
+func TestGenerator_Generate_Backup_EmptyOutputDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md":         &fstest.MapFile{Data: []byte("old readme")},
+		"pkg/config.ini":    &fstest.MapFile{Data: []byte("foo = bar")},
+		"notes/todo.txt":    &fstest.MapFile{Data: []byte("write tests")},
+		"keep/secret.txt":   &fstest.MapFile{Data: []byte("kept")},
+		".drydockkeep":      &fstest.MapFile{Data: []byte("/keep/\n")},
+		"LICENSE":           &fstest.MapFile{Data: []byte("old license")},
+		"pkg/nested/a.conf": &fstest.MapFile{Data: []byte("a")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithEmptyOutputDir(true), WithBackup(""))
+	err := g.Generate(ctx,
+		PlainFile("README.md", "new readme"),
+		PlainFile("main.go", "package main"),
+	)
+	require.NoError(t, err)
+
+	id := g.BackupID()
+	require.NotEmpty(t, id)
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS[path.Join(id, "README.md")].Data))
+	assert.Equal(t, "foo = bar", string(tmpfs.MapFS[path.Join(id, "pkg/config.ini")].Data))
+	assert.Equal(t, "a", string(tmpfs.MapFS[path.Join(id, "pkg/nested/a.conf")].Data))
+	assert.Equal(t, "write tests", string(tmpfs.MapFS[path.Join(id, "notes/todo.txt")].Data))
+	assert.Equal(t, "old license", string(tmpfs.MapFS[path.Join(id, "LICENSE")].Data))
+	assert.NotContains(t, tmpfs.MapFS, path.Join(id, "keep/secret.txt"))
+	assert.NotContains(t, tmpfs.MapFS, path.Join(id, "main.go"))
+
+	assert.NotContains(t, tmpfs.MapFS, "LICENSE")
+	assert.NotContains(t, tmpfs.MapFS, "notes/todo.txt")
+	assert.Equal(t, "kept", string(tmpfs.MapFS["keep/secret.txt"].Data))
+
+	backups, err := ListBackups(tmpfs, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, backups)
+
+	err = RestoreBackup(tmpfs, id)
+	require.NoError(t, err)
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "write tests", string(tmpfs.MapFS["notes/todo.txt"].Data))
+}
@@ -0,0 +1,247 @@
+package drydock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// ConflictPolicy determines what happens when a generated file already exists in the output.
+type ConflictPolicy int
+
+const (
+	// ConflictDefault falls back to [ConflictError] or [ConflictOverwrite], depending on [WithErrorOnExistingFile].
+	ConflictDefault ConflictPolicy = iota
+	// ConflictOverwrite replaces the existing file.
+	ConflictOverwrite
+	// ConflictSkip keeps the existing file and discards the generated one.
+	ConflictSkip
+	// ConflictError aborts the generation with an error wrapping [fs.ErrExist].
+	ConflictError
+	// ConflictBackup keeps a copy of the existing file as `<name>.bak` before replacing it,
+	// or in the backup dir if [WithBackup] is set.
+	ConflictBackup
+	// ConflictMerge combines the existing and the generated file using the [MergeFunc] passed to [OnConflictMerge].
+	ConflictMerge
+	// ConflictPrompt asks the [ConflictResolver] set by [WithConflictResolver] which policy to apply.
+	ConflictPrompt
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictDefault:
+		return "default"
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	case ConflictError:
+		return "error"
+	case ConflictBackup:
+		return "backup"
+	case ConflictMerge:
+		return "merge"
+	case ConflictPrompt:
+		return "prompt"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+	}
+}
+
+// MergeFunc combines the contents of an existing file with the newly generated contents and writes the result to w.
+type MergeFunc func(existing []byte, generated []byte, w io.Writer) error
+
+// OnConflict sets the policy applied when file already exists in the output, overriding [WithErrorOnExistingFile].
+// When file is a [Directory] the policy applies to all of its entries that don't set their own.
+func OnConflict(file File, policy ConflictPolicy) File {
+	return withConflictSetting(file, conflictSetting{policy: policy})
+}
+
+// OnConflictMerge is like [OnConflict] with [ConflictMerge], using merge to combine the existing and generated contents.
+func OnConflictMerge(file File, merge MergeFunc) File {
+	return withConflictSetting(file, conflictSetting{policy: ConflictMerge, merge: merge})
+}
+
+func withConflictSetting(file File, setting conflictSetting) File {
+	if dir, ok := file.(Directory); ok {
+		return &conflictDir{Directory: dir, setting: setting}
+	}
+
+	return &conflictFile{File: file, setting: setting}
+}
+
+type conflictFile struct {
+	File
+	setting conflictSetting
+}
+
+// Unwrap returns the wrapped [File].
+func (f *conflictFile) Unwrap() File {
+	return f.File
+}
+
+func (f *conflictFile) conflictSetting() conflictSetting {
+	return f.setting
+}
+
+type conflictDir struct {
+	Directory
+	setting conflictSetting
+}
+
+// Unwrap returns the wrapped [Directory].
+func (d *conflictDir) Unwrap() File {
+	return d.Directory
+}
+
+func (d *conflictDir) conflictSetting() conflictSetting {
+	return d.setting
+}
+
+type conflictSetter interface {
+	Unwrap() File
+	conflictSetting() conflictSetting
+}
+
+// Conflict describes a generated file that already exists in the output.
+type Conflict struct {
+	Path      string
+	Existing  []byte
+	Generated []byte
+}
+
+// ConflictResolver decides how to handle conflicts of files with the [ConflictPrompt] policy,
+// e.g. by asking the user. It must return a policy other than [ConflictPrompt].
+// Returning [ConflictMerge] is only valid for files with a [MergeFunc].
+type ConflictResolver interface {
+	ResolveConflict(ctx context.Context, conflict *Conflict) (ConflictPolicy, error)
+}
+
+// ConflictResolverFunc is an adapter to allow the use of ordinary functions as [ConflictResolver].
+type ConflictResolverFunc func(ctx context.Context, conflict *Conflict) (ConflictPolicy, error)
+
+// ResolveConflict implements [ConflictResolver].
+func (f ConflictResolverFunc) ResolveConflict(ctx context.Context, conflict *Conflict) (ConflictPolicy, error) {
+	return f(ctx, conflict)
+}
+
+var ErrUnresolvedConflict = errors.New("unresolved conflict")
+
+type conflictSetting struct {
+	policy ConflictPolicy
+	merge  MergeFunc
+}
+
+// conflictSetting returns the setting for the file or the closest parent directory that has one.
+func (r *run) conflictSetting(file string) conflictSetting {
+	for p := file; ; p = path.Dir(p) {
+		if s, ok := r.conflicts[p]; ok {
+			return s
+		}
+
+		if p == "." || p == "/" {
+			return conflictSetting{policy: ConflictDefault}
+		}
+	}
+}
+
+func (r *run) resolveConflict(ctx context.Context, file string) (ConflictPolicy, error) {
+	setting := r.conflictSetting(file)
+	policy := setting.policy
+
+	if policy == ConflictPrompt {
+		if r.conflictResolver == nil {
+			return policy, fmt.Errorf("%w: %s: no ConflictResolver configured", ErrUnresolvedConflict, file)
+		}
+
+		conflict, err := r.readConflict(file)
+		if err != nil {
+			return policy, err
+		}
+
+		policy, err = r.conflictResolver.ResolveConflict(ctx, conflict)
+		if err != nil {
+			return policy, fmt.Errorf("%w: %s: %w", ErrUnresolvedConflict, file, err)
+		}
+
+		if policy == ConflictPrompt {
+			return policy, fmt.Errorf("%w: %s: resolver returned %s", ErrUnresolvedConflict, file, policy)
+		}
+	}
+
+	if policy == ConflictDefault {
+		if r.errorOnExistingFile {
+			policy = ConflictError
+		} else {
+			policy = ConflictOverwrite
+		}
+	}
+
+	switch policy {
+	case ConflictError:
+		return policy, fmt.Errorf("file already exits %s: %w", file, fs.ErrExist)
+	case ConflictMerge:
+		return policy, r.mergeConflict(file, setting.merge)
+	case ConflictDefault, ConflictOverwrite, ConflictSkip, ConflictBackup, ConflictPrompt:
+	}
+
+	return policy, nil
+}
+
+func (r *run) readConflict(file string) (*Conflict, error) {
+	existing, err := fs.ReadFile(r.output, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading existing file '%s': %w", file, err)
+	}
+
+	generated, err := fs.ReadFile(r.tmptfs, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading temporary file '%s': %w", file, err)
+	}
+
+	return &Conflict{Path: file, Existing: existing, Generated: generated}, nil
+}
+
+// mergeConflict replaces the staged file with the result of merging it with the existing file.
+func (r *run) mergeConflict(file string, merge MergeFunc) error {
+	if merge == nil {
+		return fmt.Errorf("%w: %s: no MergeFunc to merge with", ErrUnresolvedConflict, file)
+	}
+
+	conflict, err := r.readConflict(file)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.restage(file, func(w io.Writer) error {
+		return merge(conflict.Existing, conflict.Generated, w)
+	})
+	if err != nil {
+		return fmt.Errorf("error merging file '%s': %w", file, err)
+	}
+
+	return nil
+}
+
+// backupFile copies the existing file to `<file>.bak`.
+func (r *run) backupFile(file string) error {
+	contents, err := fs.ReadFile(r.output, file)
+	if err != nil {
+		return fmt.Errorf("error backing up file '%s': %w", file, err)
+	}
+
+	err = r.recordWrite(file + ".bak")
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(r.output, file+".bak", contents)
+	if err != nil {
+		return fmt.Errorf("error backing up file '%s': %w", file, err)
+	}
+
+	return nil
+}
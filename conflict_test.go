@@ -0,0 +1,168 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_OnConflict(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	newFS := func() *MapFSOutputFS {
+		return &MapFSOutputFS{MapFS: fstest.MapFS{
+			"LICENSE":     &fstest.MapFile{Data: []byte("existing license")},
+			"go.mod":      &fstest.MapFile{Data: []byte("module existing\n")},
+			"main.go":     &fstest.MapFile{Data: []byte("package existing")},
+			"cmd/main.go": &fstest.MapFile{Data: []byte("package existing")},
+		}, baseDir: "."}
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		result, err := g.GenerateWithResult(ctx, OnConflict(PlainFile("LICENSE", "new license"), ConflictSkip), PlainFile("README.md", "# drydock"))
+		require.NoError(t, err)
+		assert.Equal(t, "existing license", string(tmpfs.MapFS["LICENSE"].Data))
+		assert.Equal(t, "# drydock", string(tmpfs.MapFS["README.md"].Data))
+		assert.Equal(t, 1, result.Count(OutcomeSkipped))
+		assert.Contains(t, result.Files, FileResult{Path: "LICENSE", Outcome: OutcomeSkipped})
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs, WithErrorOnExistingFile(true))
+		err := g.Generate(ctx, OnConflict(PlainFile("LICENSE", "new license"), ConflictOverwrite))
+		require.NoError(t, err)
+		assert.Equal(t, "new license", string(tmpfs.MapFS["LICENSE"].Data))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs, WithErrorOnExistingFile(false))
+		err := g.Generate(ctx, PlainFile("LICENSE", "new license"), OnConflict(PlainFile("main.go", "package main"), ConflictError))
+		require.ErrorIs(t, err, fs.ErrExist)
+		assert.Equal(t, "existing license", string(tmpfs.MapFS["LICENSE"].Data))
+		assert.Equal(t, "package existing", string(tmpfs.MapFS["main.go"].Data))
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, OnConflict(PlainFile("main.go", "package main"), ConflictBackup))
+		require.NoError(t, err)
+		assert.Equal(t, "package main", string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, "package existing", string(tmpfs.MapFS["main.go.bak"].Data))
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, OnConflictMerge(PlainFile("go.mod", "require example.com/dep v1.0.0\n"), func(existing, generated []byte, w io.Writer) error {
+			_, err := w.Write(append(existing, generated...))
+			return err
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "module existing\nrequire example.com/dep v1.0.0\n", string(tmpfs.MapFS["go.mod"].Data))
+	})
+
+	t.Run("Merge Without MergeFunc", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, OnConflict(PlainFile("go.mod", "module new"), ConflictMerge))
+		require.ErrorIs(t, err, ErrUnresolvedConflict)
+	})
+
+	t.Run("Directory Policy", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, OnConflict(Dir("cmd",
+			PlainFile("main.go", "package main"),
+			OnConflict(PlainFile("other.go", "package main"), ConflictError),
+		), ConflictSkip))
+		require.NoError(t, err)
+		assert.Equal(t, "package existing", string(tmpfs.MapFS["cmd/main.go"].Data))
+		assert.Equal(t, "package main", string(tmpfs.MapFS["cmd/other.go"].Data))
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		tmpfs := newFS()
+
+		var conflicts []*Conflict
+		resolver := ConflictResolverFunc(func(_ context.Context, conflict *Conflict) (ConflictPolicy, error) {
+			conflicts = append(conflicts, conflict)
+			if conflict.Path == "LICENSE" {
+				return ConflictSkip, nil
+			}
+			return ConflictOverwrite, nil
+		})
+
+		g := NewGenerator(tmpfs, WithConflictResolver(resolver))
+		err := g.Generate(ctx, OnConflict(Dir(".",
+			PlainFile("LICENSE", "new license"),
+			PlainFile("main.go", "package main"),
+			PlainFile("README.md", "# drydock"),
+		), ConflictPrompt))
+		require.NoError(t, err)
+
+		require.Len(t, conflicts, 2)
+		assert.Equal(t, &Conflict{Path: "LICENSE", Existing: []byte("existing license"), Generated: []byte("new license")}, conflicts[0])
+		assert.Equal(t, "main.go", conflicts[1].Path)
+
+		assert.Equal(t, "existing license", string(tmpfs.MapFS["LICENSE"].Data))
+		assert.Equal(t, "package main", string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, "# drydock", string(tmpfs.MapFS["README.md"].Data))
+	})
+
+	t.Run("Prompt Canceled EmptyOutputDir", func(t *testing.T) {
+		tmpfs := newFS()
+		tmpfs.MapFS["old.txt"] = &fstest.MapFile{Data: []byte("old")}
+
+		resolver := ConflictResolverFunc(func(context.Context, *Conflict) (ConflictPolicy, error) {
+			return ConflictPrompt, context.Canceled
+		})
+
+		g := NewGenerator(tmpfs, WithEmptyOutputDir(true), WithKeep("LICENSE"), WithConflictResolver(resolver))
+		err := g.Generate(ctx, OnConflict(PlainFile("LICENSE", "new license"), ConflictPrompt), PlainFile("README.md", "# drydock"))
+		require.ErrorIs(t, err, context.Canceled)
+
+		assert.Equal(t, "old", string(tmpfs.MapFS["old.txt"].Data))
+		assert.Equal(t, "existing license", string(tmpfs.MapFS["LICENSE"].Data))
+		assert.NotContains(t, tmpfs.MapFS, "README.md")
+	})
+
+	t.Run("Prompt Without Resolver", func(t *testing.T) {
+		tmpfs := newFS()
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, OnConflict(PlainFile("main.go", "package main"), ConflictPrompt))
+		require.ErrorIs(t, err, ErrUnresolvedConflict)
+		assert.Equal(t, "package existing", string(tmpfs.MapFS["main.go"].Data))
+	})
+}
+
+func TestGenerator_Generate_OnConflict_OSOutputFS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	outpath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outpath, "go.mod"), []byte("module existing\n"), 0o644))
+
+	g := NewGenerator(NewOSOutputFS(outpath))
+	err := g.Generate(ctx, OnConflictMerge(PlainFile("go.mod", "go 1.23\n"), func(existing, generated []byte, w io.Writer) error {
+		_, err := w.Write(append(existing, generated...))
+		return err
+	}))
+	require.NoError(t, err)
+
+	gomod, err := os.ReadFile(filepath.Join(outpath, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module existing\ngo 1.23\n", string(gomod))
+}
@@ -38,21 +38,49 @@ func TemplatedFile(name string, template Template, data any) File {
 	return &templatedFile{name: name, template: template, data: data}
 }
 
-type tmplfunc func(w io.Writer, data any) error
+// TemplatedFileStr parses templateStr as a [text/template] with the functions of [FuncMap] and executes it with data.
+func TemplatedFileStr(name string, templateStr string, data any) File {
+	return TemplatedFileStrDelims(name, templateStr, "", "", data)
+}
 
-func (f tmplfunc) Execute(w io.Writer, data any) error {
-	return f(w, data)
+// TemplatedFileStrDelims is like [TemplatedFileStr] but uses left and right as action delimiters instead of
+// `{{` and `}}`, e.g. to generate files that are templates themselves, like Helm charts or GitHub Actions workflows.
+// In both, the body of a `{{verbatim}}...{{end}}` block, written with the respective delimiters,
+// is copied to the output as is, without being parsed as a template. Blocks like `{{if}}...{{end}}` in the body
+// must be balanced, so the verbatim block ends at the matching `{{end}}`, delimiters in string literals and
+// comments of actions are ignored.
+func TemplatedFileStrDelims(name string, templateStr string, left string, right string, data any) File {
+	return &templatedFileStr{name: name, text: templateStr, left: left, right: right, data: data}
 }
 
-func TemplatedFileStr(name string, templateStr string, data any) File {
-	return &templatedFile{name: name, template: tmplfunc(func(w io.Writer, data any) error {
-		t, err := template.New(name).Parse(templateStr)
-		if err != nil {
-			return err
-		}
+type templatedFileStr struct {
+	name  string
+	text  string
+	left  string
+	right string
+	data  any
+	funcs template.FuncMap
+}
+
+func (f *templatedFileStr) Name() string {
+	return f.name
+}
+
+// WriteTo implements [io.WriterTo]
+func (f *templatedFileStr) WriteTo(w io.Writer) (int64, error) {
+	t, err := parseTemplate(f.name, f.text, f.left, f.right, f.funcs)
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, t.Execute(w, f.data)
+}
 
-		return t.Execute(w, data)
-	}), data: data}
+// withFuncs returns a copy of the file using funcs in addition to the [FuncMap].
+func (f *templatedFileStr) withFuncs(funcs template.FuncMap) File {
+	withFuncs := *f
+	withFuncs.funcs = funcs
+	return &withFuncs
 }
 
 type templatedFile struct {
@@ -72,6 +100,8 @@ func (f *templatedFile) WriteTo(w io.Writer) (int64, error) {
 
 // ModifyFile can modify an existing file's contents.
 // [Generator.Generate] will return an error, if the file doesn't exist yet.
+// If the file was generated or modified earlier in the same run, the modification is applied to
+// those contents, so multiple modifications of the same file are applied in order.
 func ModifyFile(name string, modifier func(contents []byte, w io.Writer) error) File {
 	return &modFile{
 		name:     name,
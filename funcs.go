@@ -0,0 +1,404 @@
+package drydock
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FuncMap returns the functions available in templates parsed by [TemplatedFileStr] and [TemplatedFileStrDelims]:
+//
+//   - camelCase, pascalCase, snakeCase and kebabCase convert between naming conventions, splitting words at
+//     separators, case changes and the end of initialisms, e.g. `HTTPServer` becomes `http_server`.
+//   - goIdent converts to an exported Go identifier, keeping common initialisms and their plurals upper case,
+//     e.g. `user_id` becomes `UserID` and `user_ids` becomes `UserIDs`. Identifiers that don't start with an upper
+//     case letter are prefixed with `X`, e.g. `2fa_code` becomes `X2faCode`.
+//   - goPackageName converts an import path or name to a Go package name, e.g. `github.com/org/my-app/v2` becomes `myapp`.
+//   - plural and singular inflect English nouns.
+//   - indent prefixes every line with the given number of spaces, quote returns a double-quoted Go string literal.
+//   - toJSON and toYAML marshal values.
+//   - now returns the current time, or the time set by [WithModTime] or `SOURCE_DATE_EPOCH` when generating files,
+//     and uuid returns a random version 4 UUID.
+//
+// Use [WithFuncs] to add or override functions.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"camelCase":     camelCase,
+		"pascalCase":    pascalCase,
+		"snakeCase":     snakeCase,
+		"kebabCase":     kebabCase,
+		"goIdent":       goIdent,
+		"goPackageName": goPackageName,
+		"plural":        plural,
+		"singular":      singular,
+		"indent":        indent,
+		"quote":         quote,
+		"toJSON":        toJSON,
+		"toYAML":        toYAML,
+		"now":           time.Now,
+		"uuid":          newUUID,
+	}
+}
+
+// WithFuncs adds funcs to the [FuncMap] of templates parsed by [TemplatedFileStr] and [TemplatedFileStrDelims],
+// replacing built-in functions with the same name.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(g *Generator) {
+		if g.funcs == nil {
+			g.funcs = template.FuncMap{}
+		}
+
+		for name, fn := range funcs {
+			g.funcs[name] = fn
+		}
+	}
+}
+
+// templateFuncs returns the functions added to the [FuncMap] of templates, `now` returns the configured
+// modification time, unless it is zero.
+func (g *Generator) templateFuncs() template.FuncMap {
+	funcs := make(template.FuncMap, len(g.funcs)+1)
+	if !g.modTime.IsZero() {
+		modTime := g.modTime
+		funcs["now"] = func() time.Time { return modTime }
+	}
+
+	for name, fn := range g.funcs {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// splitWords splits s into words at every rune that is neither a letter nor a digit, before an upper case letter
+// following a lower case letter or digit, and before the last letter of an initialism followed by a lower case
+// letter, so `HTTPServer` becomes `HTTP` and `Server`, but `IDs` stays one word. Digits belong to the preceding word.
+func splitWords(s string) []string {
+	runes := []rune(s)
+
+	var words []string
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start != -1 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+
+			continue
+		}
+
+		if start == -1 {
+			start = i
+			continue
+		}
+
+		prev := runes[i-1]
+		if unicode.IsUpper(r) {
+			lowerBefore := unicode.IsLower(prev) || unicode.IsDigit(prev)
+			initialismEnd := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+				!isPluralSuffix(runes, i+1)
+
+			if lowerBefore || initialismEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+
+	if start != -1 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// isPluralSuffix reports whether the rune at i is a lone `s` ending a word, like in `IDs`.
+func isPluralSuffix(runes []rune, i int) bool {
+	return runes[i] == 's' && (i+1 == len(runes) || !unicode.IsLower(runes[i+1]))
+}
+
+// capitalize returns word with the first rune in title case and the rest in lower case.
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) == 0 {
+		return ""
+	}
+
+	runes[0] = unicode.ToTitle(runes[0])
+
+	return string(runes)
+}
+
+func camelCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = capitalize(w)
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+func pascalCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+
+	return strings.Join(words, "")
+}
+
+func snakeCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "_"))
+}
+
+func kebabCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "-"))
+}
+
+// goInitialisms are upper cased by goIdent, like golint does.
+var goInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "LHS": true, "QPS": true,
+	"RAM": true, "RHS": true, "RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true, "URI": true, "URL": true,
+	"UTF8": true, "VM": true, "XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+func goIdent(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		if upper := strings.ToUpper(w); goInitialisms[upper] {
+			words[i] = upper
+		} else if singular, ok := strings.CutSuffix(w, "s"); ok && goInitialisms[strings.ToUpper(singular)] && w != strings.ToUpper(w) {
+			words[i] = strings.ToUpper(singular) + "s"
+		} else {
+			words[i] = capitalize(w)
+		}
+	}
+
+	ident := strings.Join(words, "")
+	if !token.IsExported(ident) {
+		ident = "X" + ident
+	}
+
+	return ident
+}
+
+func goPackageName(s string) string {
+	segments := strings.Split(strings.Trim(s, "/"), "/")
+
+	name := segments[len(segments)-1]
+	if len(segments) > 1 && isMajorVersion(name) {
+		name = segments[len(segments)-2]
+	}
+
+	name = strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(name), "-go"), "go-")
+
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	name = b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) || token.IsKeyword(name) {
+		name = "pkg" + name
+	}
+
+	return name
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+var (
+	irregularPlurals = map[string]string{
+		"child": "children", "person": "people", "man": "men", "woman": "women", "tooth": "teeth", "foot": "feet",
+		"mouse": "mice", "goose": "geese", "ox": "oxen", "index": "indices", "matrix": "matrices", "vertex": "vertices",
+		"criterion": "criteria", "datum": "data", "medium": "media", "analysis": "analyses", "axis": "axes",
+		"crisis": "crises", "thesis": "theses", "quiz": "quizzes", "leaf": "leaves", "life": "lives", "knife": "knives",
+		"wife": "wives", "half": "halves", "wolf": "wolves", "shelf": "shelves", "calf": "calves", "self": "selves",
+	}
+	irregularSingulars = invert(irregularPlurals)
+	uncountables       = map[string]bool{
+		"data": true, "equipment": true, "feedback": true, "fish": true, "information": true, "metadata": true,
+		"money": true, "news": true, "rice": true, "series": true, "sheep": true, "species": true, "software": true,
+	}
+)
+
+func invert(m map[string]string) map[string]string {
+	inverted := make(map[string]string, len(m))
+	for k, v := range m {
+		inverted[v] = k
+	}
+
+	return inverted
+}
+
+// inflect replaces the last word of s using fn, keeping its case.
+func inflect(s string, fn func(word string) string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return s
+	}
+
+	last := words[len(words)-1]
+	idx := strings.LastIndex(s, last)
+	lower := strings.ToLower(last)
+
+	inflected := fn(lower)
+	if inflected == lower {
+		return s
+	}
+
+	if s == strings.ToUpper(s) && len([]rune(last)) > 1 {
+		inflected = strings.ToUpper(inflected)
+	} else {
+		inflected = matchCase(last, inflected)
+	}
+
+	return s[:idx] + inflected + s[idx+len(last):]
+}
+
+// matchCase copies the case of the runes of word to the common prefix of inflected, e.g. `IDs` and `id` become `ID`.
+func matchCase(word string, inflected string) string {
+	wordRunes := []rune(word)
+	runes := []rune(inflected)
+	for i := 0; i < len(runes) && i < len(wordRunes); i++ {
+		if unicode.ToLower(wordRunes[i]) != runes[i] {
+			break
+		}
+
+		runes[i] = wordRunes[i]
+	}
+
+	return string(runes)
+}
+
+func plural(s string) string {
+	return inflect(s, func(word string) string {
+		if uncountables[word] {
+			return word
+		}
+
+		if p, ok := irregularPlurals[word]; ok {
+			return p
+		}
+
+		if _, ok := irregularSingulars[word]; ok {
+			return word
+		}
+
+		switch {
+		case hasSuffix(word, "s", "x", "z", "ch", "sh"):
+			return word + "es"
+		case strings.HasSuffix(word, "y") && len(word) > 1 && !isVowel(word[len(word)-2]):
+			return word[:len(word)-1] + "ies"
+		default:
+			return word + "s"
+		}
+	})
+}
+
+func singular(s string) string {
+	return inflect(s, func(word string) string {
+		if uncountables[word] {
+			return word
+		}
+
+		if sg, ok := irregularSingulars[word]; ok {
+			return sg
+		}
+
+		if _, ok := irregularPlurals[word]; ok {
+			return word
+		}
+
+		switch {
+		case strings.HasSuffix(word, "ies") && len(word) > 3:
+			return word[:len(word)-3] + "y"
+		case hasSuffix(word, "sses", "xes", "zzes", "ches", "shes"):
+			return word[:len(word)-2]
+		case strings.HasSuffix(word, "ses") && hasSuffix(word[:len(word)-2], "us", "is"):
+			return word[:len(word)-2]
+		case strings.HasSuffix(word, "s") && !hasSuffix(word, "ss", "us", "is"):
+			return word[:len(word)-1]
+		default:
+			return word
+		}
+	})
+}
+
+func hasSuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) != -1
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func quote(v any) string {
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func toYAML(v any) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
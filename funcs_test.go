@@ -0,0 +1,185 @@
+package drydock
+
+import (
+	"context"
+	"go/token"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncMap_Case(t *testing.T) {
+	tt := []struct {
+		in     string
+		camel  string
+		pascal string
+		snake  string
+		kebab  string
+		goID   string
+	}{
+		{in: "HTTPServer", camel: "httpServer", pascal: "HttpServer", snake: "http_server", kebab: "http-server", goID: "HTTPServer"},
+		{in: "userID", camel: "userId", pascal: "UserId", snake: "user_id", kebab: "user-id", goID: "UserID"},
+		{in: "user_id", camel: "userId", pascal: "UserId", snake: "user_id", kebab: "user-id", goID: "UserID"},
+		{in: "XMLHttpRequest", camel: "xmlHttpRequest", pascal: "XmlHttpRequest", snake: "xml_http_request", kebab: "xml-http-request", goID: "XMLHTTPRequest"},
+		{in: "getUserIDs", camel: "getUserIds", pascal: "GetUserIds", snake: "get_user_ids", kebab: "get-user-ids", goID: "GetUserIDs"},
+		{in: "listURLs", camel: "listUrls", pascal: "ListUrls", snake: "list_urls", kebab: "list-urls", goID: "ListURLs"},
+		{in: "user_ids", camel: "userIds", pascal: "UserIds", snake: "user_ids", kebab: "user-ids", goID: "UserIDs"},
+		{in: "my-app name", camel: "myAppName", pascal: "MyAppName", snake: "my_app_name", kebab: "my-app-name", goID: "MyAppName"},
+		{in: "Http2Server", camel: "http2Server", pascal: "Http2Server", snake: "http2_server", kebab: "http2-server", goID: "Http2Server"},
+		{in: "utf8_string", camel: "utf8String", pascal: "Utf8String", snake: "utf8_string", kebab: "utf8-string", goID: "UTF8String"},
+		{in: "APIKey", camel: "apiKey", pascal: "ApiKey", snake: "api_key", kebab: "api-key", goID: "APIKey"},
+		{in: "ÄpfelUndBirnen", camel: "äpfelUndBirnen", pascal: "ÄpfelUndBirnen", snake: "äpfel_und_birnen", kebab: "äpfel-und-birnen", goID: "ÄpfelUndBirnen"},
+		{in: "straße_größe", camel: "straßeGröße", pascal: "StraßeGröße", snake: "straße_größe", kebab: "straße-größe", goID: "StraßeGröße"},
+		{in: "ΑθήναΠόλη", camel: "αθήναΠόλη", pascal: "ΑθήναΠόλη", snake: "αθήνα_πόλη", kebab: "αθήνα-πόλη", goID: "ΑθήναΠόλη"},
+		{in: "東京 tower", camel: "東京Tower", pascal: "東京Tower", snake: "東京_tower", kebab: "東京-tower", goID: "X東京Tower"},
+		{in: "2fa_code", camel: "2faCode", pascal: "2faCode", snake: "2fa_code", kebab: "2fa-code", goID: "X2faCode"},
+		{in: "  __leading--trailing__ ", camel: "leadingTrailing", pascal: "LeadingTrailing", snake: "leading_trailing", kebab: "leading-trailing", goID: "LeadingTrailing"},
+		{in: "", camel: "", pascal: "", snake: "", kebab: "", goID: "X"},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.camel, camelCase(tt.in), "camelCase")
+			assert.Equal(t, tt.pascal, pascalCase(tt.in), "pascalCase")
+			assert.Equal(t, tt.snake, snakeCase(tt.in), "snakeCase")
+			assert.Equal(t, tt.kebab, kebabCase(tt.in), "kebabCase")
+			assert.Equal(t, tt.goID, goIdent(tt.in), "goIdent")
+			assert.True(t, token.IsExported(goIdent(tt.in)), "goIdent exported")
+		})
+	}
+}
+
+func TestFuncMap_GoPackageName(t *testing.T) {
+	tt := map[string]string{
+		"github.com/org/my-app":    "myapp",
+		"github.com/org/my-app/v2": "myapp",
+		"github.com/org/go-yaml":   "yaml",
+		"github.com/org/yaml-go":   "yaml",
+		"Config_Loader":            "configloader",
+		"type":                     "pkgtype",
+		"3d":                       "pkg3d",
+		"v2":                       "v2",
+		"größe":                    "größe",
+	}
+
+	for in, exp := range tt {
+		t.Run(in, func(t *testing.T) {
+			assert.Equal(t, exp, goPackageName(in))
+		})
+	}
+}
+
+func TestFuncMap_Inflection(t *testing.T) {
+	tt := []struct {
+		singular string
+		plural   string
+	}{
+		{singular: "user", plural: "users"},
+		{singular: "category", plural: "categories"},
+		{singular: "day", plural: "days"},
+		{singular: "box", plural: "boxes"},
+		{singular: "address", plural: "addresses"},
+		{singular: "status", plural: "statuses"},
+		{singular: "branch", plural: "branches"},
+		{singular: "database", plural: "databases"},
+		{singular: "person", plural: "people"},
+		{singular: "child", plural: "children"},
+		{singular: "analysis", plural: "analyses"},
+		{singular: "leaf", plural: "leaves"},
+		{singular: "sheep", plural: "sheep"},
+		{singular: "series", plural: "series"},
+		{singular: "Person", plural: "People"},
+		{singular: "BlogPost", plural: "BlogPosts"},
+		{singular: "blog_category", plural: "blog_categories"},
+		{singular: "UserID", plural: "UserIDs"},
+		{singular: "USER", plural: "USERS"},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.singular, func(t *testing.T) {
+			assert.Equal(t, tt.plural, plural(tt.singular), "plural")
+			assert.Equal(t, tt.singular, singular(tt.plural), "singular")
+		})
+	}
+
+	assert.Equal(t, "people", plural("people"))
+	assert.Equal(t, "person", singular("person"))
+}
+
+func TestFuncMap_Strings(t *testing.T) {
+	assert.Equal(t, "  a\n  b", indent(2, "a\nb"))
+	assert.Equal(t, `"say \"hi\""`, quote(`say "hi"`))
+	assert.Equal(t, `"42"`, quote(42))
+
+	json, err := toJSON(map[string]any{"name": "app", "ports": []int{80, 443}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"app","ports":[80,443]}`, json)
+
+	yaml, err := toYAML(map[string]any{"name": "app", "ports": []int{80, 443}})
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nports:\n    - 80\n    - 443", yaml)
+
+	id1, err := newUUID()
+	require.NoError(t, err)
+	id2, err := newUUID()
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id1)
+	assert.NotEqual(t, id1, id2)
+}
+
+func TestGenerator_Generate_FuncMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpl := `package {{ goPackageName .Module }}
+
+type {{ goIdent .Name }} struct{}
+
+// table: {{ .Name | snakeCase | plural }}
+// created: {{ now.Year }}
+`
+
+	t.Run("Default", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithModTime(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))).Generate(ctx, TemplatedFileStr("model.go", tmpl, map[string]string{
+			"Module": "github.com/org/blog-api",
+			"Name":   "blog_post",
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "package blogapi\n\ntype BlogPost struct{}\n\n// table: blog_posts\n// created: 2024\n", string(tmpfs.MapFS["model.go"].Data))
+	})
+
+	t.Run("SOURCE_DATE_EPOCH", func(t *testing.T) {
+		t.Setenv("SOURCE_DATE_EPOCH", "1262304000")
+
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs).Generate(ctx, TemplatedFileStr("year.txt", "{{ now.Year }}", nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, "2010", string(tmpfs.MapFS["year.txt"].Data))
+	})
+
+	t.Run("WithFuncs", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithFuncs(template.FuncMap{
+			"plural": func(s string) string { return s + "_table" },
+			"year":   func() int { return 2026 },
+		}))
+
+		err := g.Generate(ctx, TemplatedFileStrDelims("schema.sql", "-- [[ .Name | snakeCase | plural ]] [[ year ]]\n", "[[", "]]", map[string]string{
+			"Name": "BlogPost",
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "-- blog_post_table 2026\n", string(tmpfs.MapFS["schema.sql"].Data))
+	})
+}
@@ -1,6 +1,7 @@
 package drydock
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,21 +9,71 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
 )
 
+// Generator generates file trees in its output. A configured Generator can be reused and shared between goroutines:
+// every call to [Generator.Generate] uses its own staging area and state.
 type Generator struct {
 	errorOnExistingDir  bool
 	emptyOutputDir      bool
 	errorOnExistingFile bool
+	prune               bool
+	keep                keepList
+	conflictResolver    ConflictResolver
+	backupDir           string
+	backupRetention     int
+	undo                bool
+	modTime             time.Time
+	lock                bool
+	lockTimeout         time.Duration
+	journal             bool
+	sync                bool
+	limits              Limits
+	preserveLineEndings bool
+	userRegions         bool
+	generatedHeader     string
+	licenseHeader       string
+	licenseHeaderExts   map[string]bool
+	funcs               template.FuncMap
+
+	output       OutputFS
+	observers    []Observer
+	interceptors []Interceptor
+
+	mu       sync.Mutex
+	files    []File
+	backupID string
+}
 
-	output OutputFS
-	files  []File
-
-	tmptfs      OutputFS
-	tmpdir      string
-	tmpdirs     map[string]int
-	tmpfiles    []string
-	tmpmodified []string
+// run is the state of a single call to [Generator.Generate].
+type run struct {
+	*Generator
+
+	tmptfs        OutputFS
+	tmpdir        string
+	tmpdirs       map[string]int
+	entryDirs     map[string]bool
+	movedDirs     map[string]bool
+	tmpfiles      []string
+	tmpmodified   []string
+	conflicts     map[string]conflictSetting
+	headers       map[string]bool
+	backupID      string
+	undoRecord    *undoRecord
+	written       map[string]int64
+	stagedBytes   int64
+	policies      map[string]ConflictPolicy
+	existing      map[string]bool
+	unchanged     map[string]bool
+	cleanKeep     *keepList
+	prevManifest  *manifest
+	result        *GenerateResult
+	journalWriter *journalWriter
+	unlock        func() error
 }
 
 type Option func(g *Generator)
@@ -45,13 +96,44 @@ func WithEmptyOutputDir(b bool) Option {
 	}
 }
 
+// WithPrune enables pruning: every file the [Generator] created in a previous run with pruning enabled,
+// but which is not part of the current tree, is removed, as are any directories it created that are left empty.
+// Generated files and created directories are recorded in a manifest at [ManifestPath].
+// Unlike [WithEmptyOutputDir], files and directories not created by the [Generator] are never touched.
+// The files to be removed are reported as [OpDeleteFile] by [Staged.Ops]. Files that were generated with a header,
+// see [WithGeneratedHeader] and [GeneratedHeader], but whose header was removed are considered taken over by
+// the user and are kept.
+func WithPrune(b bool) Option {
+	return func(g *Generator) {
+		g.prune = b
+	}
+}
+
+// WithKeep protects all paths matching the gitignore-style patterns from being removed by [WithEmptyOutputDir],
+// e.g. `.git/`, `node_modules/` or `/LOCAL_NOTES.md`. Patterns are matched at any depth and
+// are extended by the patterns in the [KeepFile] in the output root, if it exists.
+func WithKeep(patterns ...string) Option {
+	return func(g *Generator) {
+		g.keep.add(patterns...)
+	}
+}
+
+// WithConflictResolver sets the [ConflictResolver] used for files with the [ConflictPrompt] policy.
+func WithConflictResolver(r ConflictResolver) Option {
+	return func(g *Generator) {
+		g.conflictResolver = r
+	}
+}
+
 func NewGenerator(output OutputFS, opts ...Option) *Generator {
 	g := &Generator{
 		errorOnExistingDir:  false,
 		errorOnExistingFile: true,
 		emptyOutputDir:      false,
 		output:              output,
-		tmpdirs:             make(map[string]int),
+		backupRetention:     DefaultBackupRetention,
+		modTime:             sourceDateEpoch(),
+		lockTimeout:         DefaultLockTimeout,
 	}
 
 	for _, opt := range opts {
@@ -61,36 +143,137 @@ func NewGenerator(output OutputFS, opts ...Option) *Generator {
 	return g
 }
 
+// Add adds files that are generated by every call to [Generator.Generate], before the files passed to it.
+// Files passed to [Generator.Generate] are only generated by that call and are not added.
 func (g *Generator) Add(files ...File) *Generator {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	g.files = append(g.files, files...)
 	return g
 }
 
+// Reset removes all files added using [Generator.Add]. The options are kept.
+func (g *Generator) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.files = nil
+}
+
+// Generate generates the files added using [Generator.Add] followed by files.
+// It is safe to call Generate concurrently, but concurrent calls writing the same paths of the output
+// should be serialised using [WithLock].
+// Generate is the same as calling [Generator.Stage] followed by [Staged.Commit].
 func (g *Generator) Generate(ctx context.Context, files ...File) error {
-	clear(g.tmpdirs)
-	clear(g.tmpdirs)
-	g.tmpfiles = []string{}
-	g.tmpmodified = []string{}
+	_, err := g.run(ctx, files...)
+	return err
+}
 
-	g.files = append(g.files, files...)
+func (g *Generator) run(ctx context.Context, files ...File) (*GenerateResult, error) {
+	r, err := g.stage(ctx, files...)
+	if err != nil {
+		return r.result, err
+	}
+
+	return (&Staged{run: r}).Commit(ctx)
+}
+
+// stage writes all files to a new staging area. The returned run is never nil, so the result can be reported
+// on failure. On failure the staging area is removed and the lock released.
+func (g *Generator) stage(ctx context.Context, files ...File) (*run, error) {
+	r, files := g.newRun(files)
+
+	unlock, err := r.acquireLock(ctx)
+	if err != nil {
+		return r, err
+	}
 
-	var err error
-	g.tmptfs, g.tmpdir, err = g.output.MkdirTemp("drydock-*")
+	err = r.stage(ctx, files)
+	if err != nil {
+		return r, errors.Join(err, unlock())
+	}
+
+	r.unlock = unlock
+
+	return r, nil
+}
+
+// newRun returns a new run of the files added using [Generator.Add] followed by files.
+func (g *Generator) newRun(files []File) (*run, []File) {
+	g.mu.Lock()
+	files = append(slices.Clone(g.files), files...)
+	g.mu.Unlock()
+
+	return &run{
+		Generator: g,
+		tmpfiles:  []string{},
+		tmpdirs:   make(map[string]int),
+		entryDirs: make(map[string]bool),
+		movedDirs: make(map[string]bool),
+		conflicts: make(map[string]conflictSetting),
+		headers:   make(map[string]bool),
+		written:   make(map[string]int64),
+		policies:  make(map[string]ConflictPolicy),
+		existing:  make(map[string]bool),
+		unchanged: make(map[string]bool),
+		result:    &GenerateResult{Files: []FileResult{}, DirsCreated: []string{}, Expanded: []Expansion{}},
+	}, files
+}
+
+func (r *run) stage(ctx context.Context, files []File) error {
+	incomplete, err := hasJournal(r.output)
+	if err != nil {
+		return err
+	}
+
+	if incomplete {
+		return fmt.Errorf("%w: use Recover to finish or roll back the commit", ErrIncompleteCommit)
+	}
+
+	stagingStart := time.Now()
+
+	r.tmptfs, r.tmpdir, err = r.output.MkdirTemp(tempDirPattern)
 	if err != nil {
 		return fmt.Errorf("error creating temporary directory: %w", err)
 	}
 
-	for _, f := range g.files {
-		err := g.generate(ctx, "", f)
+	err = r.writeTempDirOwner()
+	if err != nil {
+		return errors.Join(err, r.output.RemoveAll(r.tmpdir))
+	}
+
+	for _, f := range files {
+		err := r.generate(ctx, "", f)
 		if err != nil {
-			return errors.Join(err, g.output.RemoveAll(g.tmpdir))
+			r.emit(ctx, Event{Type: EventRollback, Err: err})
+			return errors.Join(err, r.output.RemoveAll(r.tmpdir))
 		}
 	}
 
-	return g.moveToOutput()
+	if r.prune {
+		r.prevManifest, err = readManifest(r.output)
+		if err != nil {
+			return errors.Join(err, r.output.RemoveAll(r.tmpdir))
+		}
+	}
+
+	r.result.Timings.Staging = time.Since(stagingStart)
+
+	postProcessingStart := time.Now()
+
+	err = r.resolveConflicts(ctx)
+	if err != nil {
+		r.emit(ctx, Event{Type: EventRollback, Err: err})
+		return errors.Join(err, r.output.RemoveAll(r.tmpdir))
+	}
+
+	r.result.Timings.PostProcessing = time.Since(postProcessingStart)
+
+	return nil
 }
 
-func (g *Generator) generate(ctx context.Context, parentDir string, file File) error {
+func (r *run) generate(ctx context.Context, parentDir string, file File) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -101,25 +284,47 @@ func (g *Generator) generate(ctx context.Context, parentDir string, file File) e
 		return fmt.Errorf("invalid filename/dirname")
 	}
 
+	if cs, ok := file.(conflictSetter); ok {
+		r.conflicts[path.Join(parentDir, file.Name())] = cs.conflictSetting()
+		return r.generate(ctx, parentDir, cs.Unwrap())
+	}
+
+	if hs, ok := file.(headerSetter); ok {
+		r.headers[path.Join(parentDir, file.Name())] = true
+		return r.generate(ctx, parentDir, hs.Unwrap())
+	}
+
 	if dir, ok := file.(Directory); ok {
-		return g.generateDir(ctx, parentDir, dir)
+		return r.generateDir(ctx, parentDir, dir)
 	}
 
-	return g.generateFile(parentDir, file)
+	if expander, ok := file.(Expander); ok {
+		return r.generateExpanded(ctx, parentDir, expander)
+	}
+
+	return r.generateFile(ctx, parentDir, file)
 }
 
-func (g *Generator) generateDir(ctx context.Context, parentDir string, dir Directory) error {
+func (r *run) generateDir(ctx context.Context, parentDir string, dir Directory) error {
 	dirpath := path.Join(parentDir, dir.Name())
 
-	err := g.tmptfs.Mkdir(dirpath)
+	err := r.checkDepth(dirpath)
+	if err != nil {
+		return err
+	}
+
+	err = r.tmptfs.Mkdir(dirpath)
 	if err != nil {
 		if !errors.Is(err, fs.ErrExist) {
 			return fmt.Errorf("error creating temporary dir '%s': %w", dirpath, err)
 		}
 	}
 
-	if _, exists := g.tmpdirs[dirpath]; !exists {
-		g.tmpdirs[dirpath] = len(g.tmpdirs)
+	r.entryDirs[dirpath] = true
+
+	if _, exists := r.tmpdirs[dirpath]; !exists {
+		r.tmpdirs[dirpath] = len(r.tmpdirs)
+		r.emit(ctx, Event{Type: EventDirStaged, Path: dirpath})
 	}
 
 	entries, err := dir.Entries()
@@ -128,7 +333,7 @@ func (g *Generator) generateDir(ctx context.Context, parentDir string, dir Direc
 	}
 
 	for _, f := range entries {
-		err := g.generate(ctx, dirpath, f)
+		err := r.generate(ctx, dirpath, f)
 		if err != nil {
 			return err
 		}
@@ -137,122 +342,669 @@ func (g *Generator) generateDir(ctx context.Context, parentDir string, dir Direc
 	return nil
 }
 
-func (g *Generator) generateFile(parentDir string, file File) error {
+func (r *run) generateFile(ctx context.Context, parentDir string, file File) error {
 	filepath := path.Join(parentDir, file.Name())
 
-	outfile, err := g.tmptfs.OpenFile(filepath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if tf, ok := file.(*templatedFileStr); ok {
+		file = tf.withFuncs(r.templateFuncs())
+	}
+
+	var op *FileOp
+	if modifier, ok := file.(WriterToModify); ok {
+		op = r.modifyFileOp(filepath, modifier)
+	} else if wt, ok := file.(io.WriterTo); ok {
+		op = &FileOp{Path: filepath, Write: func(w io.Writer) error {
+			_, err := wt.WriteTo(w)
+			return err
+		}}
+	} else {
+		return nil
+	}
+
+	err := r.intercept(ctx, op)
+	if err != nil {
+		return fmt.Errorf("error generating file '%s': %w", filepath, err)
+	}
+
+	if setting, ok := r.conflicts[filepath]; ok && op.Path != filepath {
+		r.conflicts[op.Path] = setting
+	}
+
+	if r.licenseHeader != "" && !op.Modification && r.wantsLicenseHeader(op.Path) {
+		op.Write = addLicenseHeader(op.Path, r.licenseHeader, op.Write)
+	}
+
+	if header := r.generatedHeaderText(filepath); header != "" && !op.Modification {
+		op.Write = addGeneratedHeader(op.Path, header, op.Write)
+	}
+
+	if r.userRegions && !op.Modification {
+		op.Write = r.keepUserRegions(op.Path, op.Write)
+	}
+
+	return r.stageFile(ctx, op)
+}
+
+// stageFile writes the file to the staging area. A modification of a file that was already generated or modified
+// in this run is applied to the staged file.
+func (r *run) stageFile(ctx context.Context, op *FileOp) error {
+	start := time.Now()
+	restage := op.Modification && r.isStaged(op.Path)
+
+	err := r.checkDepth(op.Path)
+	if err != nil {
+		return err
+	}
+
+	if !restage {
+		err = r.checkFileCount(op.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.stageParentDirs(ctx, op.Path)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	if restage {
+		n, err = r.restageFile(op)
+	} else {
+		n, err = r.writeStagedFile(op)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if op.Modification && !restage {
+		err = r.preserveFileInfo(op.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case restage:
+		r.emit(ctx, Event{Type: EventFileModified, Path: op.Path, Size: n, Duration: time.Since(start)})
+	case op.Modification:
+		r.tmpmodified = append(r.tmpmodified, op.Path)
+		r.emit(ctx, Event{Type: EventFileModified, Path: op.Path, Size: n, Duration: time.Since(start)})
+	default:
+		r.tmpfiles = append(r.tmpfiles, op.Path)
+		r.emit(ctx, Event{Type: EventFileRendered, Path: op.Path, Size: n, Duration: time.Since(start)})
+	}
+
+	return nil
+}
+
+// isStaged reports whether the file was already generated or modified in this run.
+func (r *run) isStaged(file string) bool {
+	_, staged := r.written[file]
+	return staged
+}
+
+func (r *run) writeStagedFile(op *FileOp) (int64, error) {
+	outfile, err := r.tmptfs.OpenFile(op.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
-		if !errors.Is(err, fs.ErrExist) || g.errorOnExistingFile {
-			return fmt.Errorf("error creating temporary file '%s': %w", filepath, err)
+		if !errors.Is(err, fs.ErrExist) || r.errorOnExistingFile {
+			return 0, fmt.Errorf("error creating temporary file '%s': %w", op.Path, err)
 		}
 	}
 	defer outfile.Close()
 
-	outfileWriter, ok := outfile.(io.Writer)
+	w, ok := outfile.(io.Writer)
 	if !ok {
-		return fmt.Errorf("file %s opened with FS %T is not io.Writer", filepath, g.output)
+		return 0, fmt.Errorf("file %s opened with FS %T is not io.Writer", op.Path, r.output)
 	}
 
-	if modifier, ok := file.(WriterToModify); ok {
-		return g.modifyFile(parentDir, file, modifier, outfileWriter)
+	outfileWriter := &countingWriter{w: w, check: r.checkSize(op.Path)}
+	defer func() {
+		r.written[op.Path] = outfileWriter.n
+		r.stagedBytes += outfileWriter.n
+	}()
+
+	err = op.Write(outfileWriter)
+	if err != nil {
+		if op.Modification || errors.Is(err, ErrLimitExceeded) {
+			return 0, err
+		}
+
+		return 0, fmt.Errorf("error writing to temprorar file %s: %w", op.Path, err)
 	}
 
-	wt, ok := file.(io.WriterTo)
-	if !ok {
+	return outfileWriter.n, nil
+}
+
+// restageFile replaces the staged file with the result of the modification.
+func (r *run) restageFile(op *FileOp) (int64, error) {
+	return r.restage(op.Path, op.Write)
+}
+
+// restage replaces the staged file with the contents written by write, counting them towards the [Limits].
+// The staged file may be read by write, so the contents are buffered until write is done.
+// The mode and owner of the staged file, e.g. those preserved from the original of a modification, are kept.
+func (r *run) restage(file string, write func(w io.Writer) error) (int64, error) {
+	info, err := fs.Stat(r.tmptfs, file)
+	if err != nil {
+		return 0, fmt.Errorf("error reading file info of '%s': %w", file, err)
+	}
+
+	r.stagedBytes -= r.written[file]
+
+	var b bytes.Buffer
+	bufferWriter := &countingWriter{w: &b, check: r.checkSize(file)}
+	defer func() {
+		r.written[file] = bufferWriter.n
+		r.stagedBytes += bufferWriter.n
+	}()
+
+	err = write(bufferWriter)
+	if err != nil {
+		return 0, err
+	}
+
+	err = writeFile(r.tmptfs, file, b.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("error writing to temprorar file %s: %w", file, err)
+	}
+
+	err = r.setStagedFileInfo(file, info)
+	if err != nil {
+		return 0, err
+	}
+
+	return bufferWriter.n, nil
+}
+
+// stageParentDirs creates all parent dirs of file in the staging area that haven't been staged yet,
+// e.g. when an [Interceptor] moved the file to a different directory.
+func (r *run) stageParentDirs(ctx context.Context, file string) error {
+	dir := path.Dir(file)
+	if dir == "." {
 		return nil
 	}
 
-	_, err = wt.WriteTo(outfileWriter)
+	if _, staged := r.tmpdirs[dir]; staged {
+		return nil
+	}
+
+	err := r.stageParentDirs(ctx, dir)
 	if err != nil {
-		return fmt.Errorf("error writing to temprorar file %s: %w", filepath, err)
+		return err
 	}
 
-	g.tmpfiles = append(g.tmpfiles, filepath)
+	err = r.tmptfs.Mkdir(dir)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("error creating temporary dir '%s': %w", dir, err)
+	}
+
+	r.tmpdirs[dir] = len(r.tmpdirs)
+	r.emit(ctx, Event{Type: EventDirStaged, Path: dir})
 
 	return nil
 }
 
-func (g *Generator) modifyFile(parentDir string, file File, modifier WriterToModify, outfile io.Writer) error {
-	filepath := path.Join(parentDir, file.Name())
-	var contents []byte
-	var err error
+// modifyFileOp returns the operation to modify the file. The existing file is read when the operation
+// is written, so an [Interceptor] can change which file is modified.
+func (r *run) modifyFileOp(filepath string, modifier WriterToModify) *FileOp {
+	op := &FileOp{Path: filepath, Modification: true}
 
-	if readFileFS, ok := g.output.(fs.ReadFileFS); ok {
-		contents, err = readFileFS.ReadFile(filepath)
-	} else {
-		f, openErr := g.output.Open(filepath)
-		if openErr != nil {
-			return fmt.Errorf("error reading file '%s' for modification: %w", filepath, openErr)
+	op.Write = func(w io.Writer) error {
+		// modifications of files generated or modified earlier in the run build on the staged file
+		var source fs.FS = r.output
+		if r.isStaged(op.Path) {
+			source = r.tmptfs
+		}
+
+		contents, err := fs.ReadFile(source, op.Path)
+		if err != nil {
+			return fmt.Errorf("error reading file '%s' for modification: %w", op.Path, err)
+		}
+
+		if !r.preserveLineEndings {
+			err = modifier.WriteModifiedTo(contents, w)
+			if err != nil {
+				return fmt.Errorf("error modifying file '%s': %w", op.Path, err)
+			}
+
+			return nil
+		}
+
+		style, contents := detectTextStyle(contents)
+
+		var b bytes.Buffer
+		err = modifier.WriteModifiedTo(contents, &b)
+		if err != nil {
+			return fmt.Errorf("error modifying file '%s': %w", op.Path, err)
 		}
-		defer f.Close()
-		contents, err = io.ReadAll(f)
+
+		_, err = w.Write(style.apply(b.Bytes()))
+		return err
 	}
 
+	return op
+}
+
+func (r *run) moveToOutput(ctx context.Context) (err error) {
+	defer r.output.RemoveAll(r.tmpdir) //nolint: errcheck
+	defer func() {
+		if err != nil {
+			r.emit(ctx, Event{Type: EventRollback, Err: err})
+		}
+	}()
+
+	start := time.Now()
+	stagingPostProcessing := r.result.Timings.PostProcessing
+	defer func() {
+		r.result.Timings.Commit = time.Since(start) - (r.result.Timings.PostProcessing - stagingPostProcessing)
+	}()
+
+	err = r.beginUndoRecord()
 	if err != nil {
-		return fmt.Errorf("error reading file '%s' for modification: %w", filepath, err)
+		return err
 	}
 
-	err = modifier.WriteModifiedTo(contents, outfile)
+	defer func() {
+		if err != nil {
+			r.abortUndoRecord()
+		}
+	}()
+
+	err = r.beginJournal()
 	if err != nil {
-		return fmt.Errorf("error modifying file '%s': %w", filepath, err)
+		return err
 	}
 
-	g.tmpmodified = append(g.tmpmodified, filepath)
+	err = r.finishJournal(ctx, r.commit(ctx))
+	if err != nil {
+		return err
+	}
 
-	return nil
+	if r.prune {
+		err = r.pruneStale(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.setModTimes()
+	if err != nil {
+		return err
+	}
+
+	return r.finishUndoRecord()
 }
 
-func (g *Generator) moveToOutput() error {
-	defer g.output.RemoveAll(g.tmpdir) //nolint: errcheck
+// commit moves the staged dirs and files into the output. Conflicts have already been resolved by [run.stage],
+// so nothing in the output is removed or replaced before all decisions are made.
+func (r *run) commit(ctx context.Context) error {
+	if r.emptyOutputDir {
+		if r.backupDir != "" {
+			removed, err := cleanedFiles(r.output, ".", r.cleanKeep)
+			if err != nil {
+				return err
+			}
 
-	if g.emptyOutputDir {
-		err := cleanDir(g.output, ".")
+			err = r.backupExisting(removed)
+			if err != nil {
+				return err
+			}
+		}
+
+		cleanStart := time.Now()
+		r.emit(ctx, Event{Type: EventCleanStarted, Path: "."})
+
+		err := cleanDir(r.output, ".", r.cleanKeep)
 		if err != nil {
 			return err
 		}
+
+		r.emit(ctx, Event{Type: EventCleanFinished, Path: ".", Duration: time.Since(cleanStart)})
+	}
+
+	err := r.createDirs()
+	if err != nil {
+		return err
+	}
+
+	postProcessingStart := time.Now()
+
+	overwritten := make([]string, 0, len(r.existing))
+	for _, file := range append(slices.Clone(r.tmpfiles), r.tmpmodified...) {
+		if r.existing[file] && r.policies[file] != ConflictSkip && !r.unchanged[file] {
+			overwritten = append(overwritten, file)
+		}
 	}
 
-	tmpdirs := make([]string, len(g.tmpdirs))
-	for dir, i := range g.tmpdirs {
+	err = r.backupExisting(overwritten)
+	if err != nil {
+		return err
+	}
+
+	r.result.Timings.PostProcessing += time.Since(postProcessingStart)
+
+	err = r.commitFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.syncDirs()
+}
+
+// createDirs creates the staged dirs that don't exist in the output yet. Dirs whose parent exists are not created,
+// but moved into the output as a whole by [run.commitFiles], including all their files and subdirectories.
+func (r *run) createDirs() error {
+	tmpdirs := make([]string, len(r.tmpdirs))
+	for dir, i := range r.tmpdirs {
 		tmpdirs[i] = dir
 	}
 
 	for _, dir := range tmpdirs {
-		err := g.output.Mkdir(dir)
-		if err != nil {
-			if !errors.Is(err, fs.ErrExist) || g.errorOnExistingDir {
-				return fmt.Errorf("error creating dir '%s': %s", dir, err)
+		if r.movedParent(dir) == "" {
+			exists, err := fileExists(r.output, dir)
+			if err != nil {
+				return fmt.Errorf("error creating dir '%s': %w", dir, err)
 			}
+
+			if exists {
+				continue
+			}
+
+			r.movedDirs[dir] = true
 		}
+
+		r.recordDir(dir)
+		r.result.DirsCreated = append(r.result.DirsCreated, dir)
+	}
+
+	return nil
+}
+
+// movedParent returns the dir containing p that is moved into the output as a whole, if any.
+func (r *run) movedParent(p string) string {
+	if len(r.movedDirs) == 0 {
+		return ""
 	}
 
-	for _, file := range g.tmpfiles {
-		if g.errorOnExistingFile {
-			exists, err := fileExists(g.output, file)
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if r.movedDirs[dir] {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// resolveConflicts finds the staged dirs and files that already exist in the output, determines the [ConflictPolicy]
+// for the generated files and finds the files whose contents would not change. Files removed by
+// [WithEmptyOutputDir] don't conflict.
+func (r *run) resolveConflicts(ctx context.Context) error {
+	if r.emptyOutputDir {
+		var err error
+		r.cleanKeep, err = r.loadKeepFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.errorOnExistingDir {
+		for dir := range r.tmpdirs {
+			exists, err := r.existsAfterClean(dir, true)
 			if err != nil {
-				return err
+				return fmt.Errorf("error creating dir '%s': %w", dir, err)
 			}
 
 			if exists {
-				return fmt.Errorf("file already exits %s: %w", file, fs.ErrExist)
+				return fmt.Errorf("error creating dir '%s': %w", dir, fs.ErrExist)
 			}
 		}
+	}
+
+	for _, file := range r.tmpfiles {
+		exists, err := r.existsAfterClean(file, false)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		r.existing[file] = true
+
+		r.policies[file], err = r.resolveConflict(ctx, file)
+		if err != nil {
+			return err
+		}
 
-		tmpfilepath := path.Join(g.tmpdir, file)
+		if r.policies[file] == ConflictSkip {
+			continue
+		}
 
-		err := g.output.Rename(tmpfilepath, file)
+		r.unchanged[file], err = r.sameContents(file)
 		if err != nil {
-			return fmt.Errorf("error moving file %s to %s: %w", tmpfilepath, file, err)
+			return err
 		}
 	}
 
-	for _, file := range g.tmpmodified {
-		tmpfilepath := path.Join(g.tmpdir, file)
-		err := g.output.Rename(tmpfilepath, file)
+	for _, file := range r.tmpmodified {
+		exists, err := r.existsAfterClean(file, false)
 		if err != nil {
-			return fmt.Errorf("error moving (modified) file %s to %s: %w", tmpfilepath, file, err)
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		r.existing[file] = true
+		r.unchanged[file], err = r.sameContents(file)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// existsAfterClean reports whether name exists in the output and is not removed by [WithEmptyOutputDir].
+func (r *run) existsAfterClean(name string, isDir bool) (bool, error) {
+	if r.emptyOutputDir && !r.cleanKeep.match(name, isDir) {
+		return false, nil
+	}
+
+	return fileExists(r.output, name)
+}
+
+// commitStep is the planned outcome of a staged file or of a dir that is moved as a whole.
+type commitStep struct {
+	file    string
+	outcome FileOutcome
+	// dir is set if the step moves the dir file, including all its files.
+	dir bool
+	// movedWith is the dir the file is moved with.
+	movedWith string
+}
+
+// moves reports whether the staged file or dir is moved into the output by the step.
+func (s commitStep) moves() bool {
+	return s.movedWith == "" && s.outcome != OutcomeSkipped
+}
+
+func (r *run) commitFiles(ctx context.Context) error {
+	steps := make([]commitStep, 0, len(r.movedDirs)+len(r.tmpfiles)+len(r.tmpmodified))
+	movedFiles := make(map[string][]string, len(r.movedDirs))
+
+	dirs := make([]string, 0, len(r.movedDirs))
+	for dir := range r.movedDirs {
+		dirs = append(dirs, dir)
+	}
+
+	slices.SortFunc(dirs, func(a, b string) int { return r.tmpdirs[a] - r.tmpdirs[b] })
+
+	for _, dir := range dirs {
+		steps = append(steps, commitStep{file: dir, outcome: OutcomeCreated, dir: true})
+	}
+
+	for _, file := range r.tmpfiles {
+		policy, exists := r.policies[file], r.existing[file]
+
+		if dir := r.movedParent(file); dir != "" {
+			movedFiles[dir] = append(movedFiles[dir], file)
+			steps = append(steps, commitStep{file: file, outcome: OutcomeCreated, movedWith: dir})
+			continue
+		}
+
+		switch {
+		case policy == ConflictSkip:
+			steps = append(steps, commitStep{file: file, outcome: OutcomeSkipped})
+		case r.unchanged[file]:
+			steps = append(steps, commitStep{file: file, outcome: OutcomeUnchanged})
+		case exists:
+			steps = append(steps, commitStep{file: file, outcome: OutcomeOverwritten})
+		default:
+			steps = append(steps, commitStep{file: file, outcome: OutcomeCreated})
+		}
+	}
+
+	for _, file := range r.tmpmodified {
+		if dir := r.movedParent(file); dir != "" {
+			movedFiles[dir] = append(movedFiles[dir], file)
+			steps = append(steps, commitStep{file: file, outcome: OutcomeModified, movedWith: dir})
+			continue
+		}
+
+		if r.unchanged[file] {
+			steps = append(steps, commitStep{file: file, outcome: OutcomeUnchanged})
+			continue
+		}
+
+		steps = append(steps, commitStep{file: file, outcome: OutcomeModified})
+	}
+
+	journalSteps := make([]journalStep, 0, len(steps))
+	for _, step := range steps {
+		if step.moves() {
+			journalSteps = append(journalSteps, journalStep{
+				From:    path.Join(r.tmpdir, step.file),
+				To:      step.file,
+				Existed: step.outcome != OutcomeCreated,
+				Dir:     step.dir,
+			})
+		}
+	}
+
+	err := r.journalPlan(journalSteps)
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	for _, step := range steps {
+		if step.dir {
+			err := r.commitDir(moved, step.file, movedFiles[step.file])
+			if err != nil {
+				return fmt.Errorf("error moving dir %s to %s: %w", path.Join(r.tmpdir, step.file), step.file, err)
+			}
+
+			moved++
+
+			continue
+		}
+
+		if !step.moves() {
+			r.addResult(ctx, step.file, step.outcome, 0)
+			continue
+		}
+
+		if r.policies[step.file] == ConflictBackup && r.backupDir == "" {
+			err := r.backupFile(step.file)
+			if err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+
+		err := r.commitFile(moved, step)
+		if err != nil {
+			if step.outcome == OutcomeModified {
+				return fmt.Errorf("error moving (modified) file %s to %s: %w", path.Join(r.tmpdir, step.file), step.file, err)
+			}
+
+			return fmt.Errorf("error moving file %s to %s: %w", path.Join(r.tmpdir, step.file), step.file, err)
+		}
+
+		moved++
+
+		r.addResult(ctx, step.file, step.outcome, time.Since(start))
+	}
+
+	return nil
+}
+
+func (r *run) commitFile(i int, step commitStep) error {
+	err := r.recordWrite(step.file)
+	if err != nil {
+		return err
+	}
+
+	if step.outcome != OutcomeCreated {
+		err = r.journalPreimage(step.file)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.syncFile(path.Join(r.tmpdir, step.file))
+	if err != nil {
+		return err
+	}
+
+	err = r.output.Rename(path.Join(r.tmpdir, step.file), step.file)
+	if err != nil {
+		return err
+	}
+
+	return r.journalDone(i)
+}
+
+// commitDir moves a dir that doesn't exist in the output yet, including its files, with a single rename.
+func (r *run) commitDir(i int, dir string, files []string) error {
+	for _, file := range files {
+		r.recordCreate(file)
+
+		err := r.syncFile(path.Join(r.tmpdir, file))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := r.output.Rename(path.Join(r.tmpdir, dir), dir)
+	if err != nil {
+		return err
+	}
+
+	return r.journalDone(i)
+}
+
+func (r *run) sameContents(file string) (bool, error) {
+	existing, err := fs.ReadFile(r.output, file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("error reading existing file '%s': %w", file, err)
+	}
+
+	staged, err := fs.ReadFile(r.tmptfs, file)
+	if err != nil {
+		return false, fmt.Errorf("error reading temporary file '%s': %w", file, err)
+	}
+
+	return bytes.Equal(existing, staged), nil
+}
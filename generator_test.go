@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
+	"sync"
 	"testing"
 	"testing/fstest"
 	"text/template"
@@ -340,3 +342,165 @@ func ExampleGenerator_Generate() {
 	// d bin/
 	// d pkg/
 }
+
+func TestGenerator_Generate_Reuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false)).Add(PlainFile("README.md", "readme"))
+
+	result, err := g.GenerateWithResult(ctx, PlainFile("first.txt", "first"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count(OutcomeCreated))
+
+	result, err = g.GenerateWithResult(ctx, PlainFile("second.txt", "second"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []FileResult{
+		{Path: "README.md", Outcome: OutcomeUnchanged, BytesWritten: 6},
+		{Path: "second.txt", Outcome: OutcomeCreated, BytesWritten: 6},
+	}, result.Files)
+
+	g.Reset()
+
+	result, err = g.GenerateWithResult(ctx, PlainFile("third.txt", "third"))
+	require.NoError(t, err)
+	assert.Equal(t, []FileResult{{Path: "third.txt", Outcome: OutcomeCreated, BytesWritten: 5}}, result.Files)
+}
+
+func TestGenerator_Generate_Concurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	dir := t.TempDir()
+	g := NewGenerator(NewOSOutputFS(dir)).Add(Dir("shared"))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = g.Generate(ctx, Dir(fmt.Sprintf("dir_%d", i), PlainFile("main.go", fmt.Sprintf("package dir_%d", i))))
+		}()
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		require.NoError(t, err)
+
+		contents, err := os.ReadFile(path.Join(dir, fmt.Sprintf("dir_%d", i), "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("package dir_%d", i), string(contents))
+	}
+}
+
+func TestGenerator_Generate_NewDirs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	output := &recordingFS{MapFSOutputFS: &MapFSOutputFS{MapFS: fstest.MapFS{
+		"pkg/existing.go": &fstest.MapFile{Data: []byte("package pkg")},
+	}, baseDir: "."}}
+
+	result, err := NewGenerator(output, WithUndo(true)).GenerateWithResult(ctx,
+		Dir("cmd",
+			Dir("app", PlainFile("main.go", "package main")),
+			PlainFile("doc.go", "package cmd"),
+		),
+		Dir("pkg",
+			PlainFile("pkg.go", "package pkg"),
+			Dir("sub", PlainFile("sub.go", "package sub")),
+		),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"rename cmd", "rename pkg/sub", "rename pkg/pkg.go", "rename .drydock/undo/record.json"}, output.ops)
+	assert.Equal(t, []string{"cmd", "cmd/app", "pkg/sub"}, result.DirsCreated)
+	assert.Equal(t, 4, result.Count(OutcomeCreated))
+
+	assert.Equal(t, "package main", string(output.MapFS["cmd/app/main.go"].Data))
+	assert.Equal(t, "package cmd", string(output.MapFS["cmd/doc.go"].Data))
+	assert.Equal(t, "package sub", string(output.MapFS["pkg/sub/sub.go"].Data))
+	assert.Equal(t, "package pkg", string(output.MapFS["pkg/pkg.go"].Data))
+
+	undone, err := Undo(ctx, output, false)
+	require.NoError(t, err)
+	assert.Empty(t, undone)
+	assertNotExists(t, output.MapFSOutputFS, "cmd")
+	assertNotExists(t, output.MapFSOutputFS, "pkg/sub")
+	assert.Contains(t, output.MapFS, "pkg/existing.go")
+}
+
+func BenchmarkGenerator_Generate_LargeTree(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(cancel)
+
+	files := make([]File, 0, 100)
+	for i := range 100 {
+		entries := make([]File, 0, 100)
+		for j := range 100 {
+			entries = append(entries, PlainFile(fmt.Sprintf("file_%d.go", j), fmt.Sprintf("package dir_%d", i)))
+		}
+
+		files = append(files, Dir(fmt.Sprintf("dir_%d", i), entries...))
+	}
+
+	b.Run("OSOutputFS", func(b *testing.B) {
+		for range b.N {
+			b.StopTimer()
+			output := NewOSOutputFS(b.TempDir())
+			b.StartTimer()
+
+			err := NewGenerator(output).Generate(ctx, files...)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("MapFSOutputFS", func(b *testing.B) {
+		for range b.N {
+			output := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+			err := NewGenerator(output).Generate(ctx, files...)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGenerator_Generate_ModifyFile_Chained(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	appendLine := func(line string) func(contents []byte, w io.Writer) error {
+		return func(contents []byte, w io.Writer) error {
+			_, err := w.Write(append(contents, []byte(line+"\n")...))
+			return err
+		}
+	}
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"config.ini": &fstest.MapFile{Data: []byte("foo = bar\n")},
+	}, baseDir: "."}
+
+	result, err := NewGenerator(tmpfs).GenerateWithResult(ctx,
+		TemplatedFileStr("go.mod", "module {{ . }}\n", "example.com/app"),
+		ModifyFile("go.mod", appendLine("go 1.23")),
+		ModifyFile("config.ini", appendLine("baz = bat")),
+		ModifyFile("config.ini", appendLine("qux = quux")),
+		ModifyFile("go.mod", appendLine("require example.com/lib v1.0.0")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "module example.com/app\ngo 1.23\nrequire example.com/lib v1.0.0\n", string(tmpfs.MapFS["go.mod"].Data))
+	assert.Equal(t, "foo = bar\nbaz = bat\nqux = quux\n", string(tmpfs.MapFS["config.ini"].Data))
+
+	assert.ElementsMatch(t, []FileResult{
+		{Path: "go.mod", Outcome: OutcomeCreated, BytesWritten: 62},
+		{Path: "config.ini", Outcome: OutcomeModified, BytesWritten: 31},
+	}, result.Files)
+}
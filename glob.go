@@ -0,0 +1,228 @@
+package drydock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+var ErrNoMatches = errors.New("pattern matched no files")
+
+// An Expander stands for files that are only known at generation time, like the matches of [ModifyGlob].
+// The generator calls Expand with the existing output, rooted at the directory the Expander is placed in,
+// and generates the returned files in its place.
+type Expander interface {
+	File
+	Expand(fsys fs.FS) ([]File, error)
+}
+
+// ModifyGlob applies modifier to every existing file in the output matching pattern, as if [ModifyFile] was used
+// for each of them. The pattern is relative to the directory ModifyGlob is placed in and is matched segment by
+// segment using [path.Match], where a `**` segment matches any number of directories. The `.drydock` directory,
+// version control directories like `.git`, `node_modules` and the files kept by [WithKeep] or the [KeepFile]
+// are never matched. A pattern without matches is not an error, unless [GlobModification.Required] is set.
+// The files matched in a run are listed in [GenerateResult.Expanded].
+func ModifyGlob(pattern string, modifier func(contents []byte, w io.Writer) error) *GlobModification {
+	return &GlobModification{pattern: pattern, modifier: modifier}
+}
+
+// GlobModification is the [Expander] returned by [ModifyGlob].
+type GlobModification struct {
+	pattern  string
+	modifier func(contents []byte, w io.Writer) error
+	required bool
+}
+
+// Required returns a copy of the modification that fails with [ErrNoMatches] if the pattern doesn't match any file.
+func (m *GlobModification) Required() *GlobModification {
+	required := *m
+	required.required = true
+	return &required
+}
+
+// Name returns the pattern.
+func (m *GlobModification) Name() string {
+	return m.pattern
+}
+
+// Matches returns the sorted paths of all files in fsys matching the pattern. The files actually modified
+// by a run are listed in [GenerateResult.Expanded].
+func (m *GlobModification) Matches(fsys fs.FS) ([]string, error) {
+	segments := strings.Split(path.Clean(m.pattern), "/")
+	for _, s := range segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", m.pattern, err)
+		}
+	}
+
+	root := globRoot(segments)
+
+	matches, err := globDir(fsys, root, segments)
+	if err != nil {
+		return nil, fmt.Errorf("error matching pattern '%s': %w", m.pattern, err)
+	}
+
+	slices.Sort(matches)
+
+	return matches, nil
+}
+
+// Expand implements [Expander] and returns a [ModifyFile] for every match.
+func (m *GlobModification) Expand(fsys fs.FS) ([]File, error) {
+	matches, err := m.Matches(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 && m.required {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatches, m.pattern)
+	}
+
+	files := make([]File, 0, len(matches))
+	for _, match := range matches {
+		files = append(files, ModifyFile(match, m.modifier))
+	}
+
+	return files, nil
+}
+
+// globSkipDirs are never searched for matches, they contain files of other tools.
+var globSkipDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, "node_modules": true}
+
+// globDir returns all files below dir matching the pattern segments, skipping the `.drydock` directory
+// and [globSkipDirs].
+func globDir(fsys fs.FS, dir string, segments []string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var matches []string
+	for _, e := range entries {
+		if e.Name() == "." {
+			continue
+		}
+
+		entryPath := path.Join(dir, e.Name())
+
+		if e.IsDir() {
+			if entryPath == ".drydock" || globSkipDirs[e.Name()] {
+				continue
+			}
+
+			sub, err := globDir(fsys, entryPath, segments)
+			if err != nil {
+				return nil, err
+			}
+
+			matches = append(matches, sub...)
+			continue
+		}
+
+		if matchPatternSegments(segments, strings.Split(entryPath, "/")) {
+			matches = append(matches, entryPath)
+		}
+	}
+
+	return matches, nil
+}
+
+// globRoot returns the directory made of the leading segments without wildcards,
+// which is the only part of the tree that can contain matches.
+func globRoot(segments []string) string {
+	root := "."
+	for _, s := range segments[:len(segments)-1] {
+		if strings.ContainsAny(s, `*?[\`) {
+			break
+		}
+
+		root = path.Join(root, s)
+	}
+
+	return root
+}
+
+func (r *run) generateExpanded(ctx context.Context, parentDir string, expander Expander) error {
+	name := path.Join(parentDir, expander.Name())
+
+	keep, err := r.loadKeepFile()
+	if err != nil {
+		return err
+	}
+
+	dir := path.Clean(parentDir)
+
+	var fsys fs.FS = r.output
+	if dir != "." {
+		fsys, err = fs.Sub(r.output, dir)
+		if err != nil {
+			return fmt.Errorf("error expanding '%s': %w", name, err)
+		}
+	}
+
+	files, err := expander.Expand(&keepFS{fsys: fsys, dir: dir, keep: keep})
+	if err != nil {
+		return fmt.Errorf("error expanding '%s': %w", name, err)
+	}
+
+	expansion := Expansion{Name: name, Files: make([]string, 0, len(files))}
+	for _, f := range files {
+		expansion.Files = append(expansion.Files, path.Join(parentDir, f.Name()))
+	}
+
+	r.result.Expanded = append(r.result.Expanded, expansion)
+
+	for _, f := range files {
+		err = r.generate(ctx, parentDir, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepFS hides the files matched by the keep list, dir is the directory of fsys in the output.
+type keepFS struct {
+	fsys fs.FS
+	dir  string
+	keep *keepList
+}
+
+func (k *keepFS) Open(name string) (fs.File, error) {
+	f, err := k.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+
+	if k.keep.match(path.Join(k.dir, name), stat.IsDir()) {
+		return nil, errors.Join(&fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}, f.Close())
+	}
+
+	return f, nil
+}
+
+func (k *keepFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(k.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(entries, func(e fs.DirEntry) bool {
+		return e.Name() != "." && k.keep.match(path.Join(k.dir, name, e.Name()), e.IsDir())
+	}), nil
+}
@@ -0,0 +1,115 @@
+package drydock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_ModifyGlob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	replaceImport := func(contents []byte, w io.Writer) error {
+		_, err := w.Write(bytes.ReplaceAll(contents, []byte("example.com/old"), []byte("example.com/new")))
+		return err
+	}
+
+	newFS := func() *MapFSOutputFS {
+		return &MapFSOutputFS{MapFS: fstest.MapFS{
+			"main.go":                 &fstest.MapFile{Data: []byte(`import "example.com/old"`)},
+			"internal/a.go":           &fstest.MapFile{Data: []byte(`import "example.com/old/a"`)},
+			"internal/pkg/b.go":       &fstest.MapFile{Data: []byte(`import "example.com/old/b"`)},
+			"internal/pkg/README.md":  &fstest.MapFile{Data: []byte(`example.com/old`)},
+			".drydock/internal/c.go":  &fstest.MapFile{Data: []byte(`import "example.com/old/c"`)},
+			"internal/testdata/d.txt": &fstest.MapFile{Data: []byte(`example.com/old`)},
+		}, baseDir: "."}
+	}
+
+	t.Run("Matches", func(t *testing.T) {
+		matches, err := ModifyGlob("internal/**/*.go", replaceImport).Matches(newFS())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"internal/a.go", "internal/pkg/b.go"}, matches)
+
+		matches, err = ModifyGlob("**/*.go", replaceImport).Matches(newFS())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"internal/a.go", "internal/pkg/b.go", "main.go"}, matches)
+
+		matches, err = ModifyGlob("missing/**/*.go", replaceImport).Matches(newFS())
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+
+		_, err = ModifyGlob("internal/[", replaceImport).Matches(newFS())
+		assert.Error(t, err)
+	})
+
+	t.Run("Modify", func(t *testing.T) {
+		tmpfs := newFS()
+
+		result, err := NewGenerator(tmpfs).GenerateWithResult(ctx, ModifyGlob("internal/**/*.go", replaceImport))
+		require.NoError(t, err)
+
+		assert.Equal(t, `import "example.com/old"`, string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, `import "example.com/new/a"`, string(tmpfs.MapFS["internal/a.go"].Data))
+		assert.Equal(t, `import "example.com/new/b"`, string(tmpfs.MapFS["internal/pkg/b.go"].Data))
+		assert.Equal(t, `example.com/old`, string(tmpfs.MapFS["internal/pkg/README.md"].Data))
+
+		assert.Equal(t, 2, result.Count(OutcomeModified))
+		assert.Equal(t, []Expansion{
+			{Name: "internal/**/*.go", Files: []string{"internal/a.go", "internal/pkg/b.go"}},
+		}, result.Expanded)
+	})
+
+	t.Run("Relative to Dir", func(t *testing.T) {
+		tmpfs := newFS()
+
+		result, err := NewGenerator(tmpfs).GenerateWithResult(ctx, Dir("internal", ModifyGlob("*.go", replaceImport)))
+		require.NoError(t, err)
+		assert.Equal(t, []Expansion{{Name: "internal/*.go", Files: []string{"internal/a.go"}}}, result.Expanded)
+
+		assert.Equal(t, `import "example.com/new/a"`, string(tmpfs.MapFS["internal/a.go"].Data))
+		assert.Equal(t, `import "example.com/old/b"`, string(tmpfs.MapFS["internal/pkg/b.go"].Data))
+	})
+
+	t.Run("Skips state dir", func(t *testing.T) {
+		tmpfs := newFS()
+
+		err := NewGenerator(tmpfs).Generate(ctx, ModifyGlob("**/*.go", replaceImport))
+		require.NoError(t, err)
+
+		assert.Equal(t, `import "example.com/new"`, string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, `import "example.com/old/c"`, string(tmpfs.MapFS[".drydock/internal/c.go"].Data))
+	})
+
+	t.Run("Skips tool dirs and kept files", func(t *testing.T) {
+		tmpfs := newFS()
+		tmpfs.MapFS[".git/hooks/pre-commit.go"] = &fstest.MapFile{Data: []byte(`import "example.com/old/git"`)}
+		tmpfs.MapFS["web/node_modules/dep/index.go"] = &fstest.MapFile{Data: []byte(`import "example.com/old/dep"`)}
+		tmpfs.MapFS["internal/pkg/vendor.go"] = &fstest.MapFile{Data: []byte(`import "example.com/old/vendor"`)}
+
+		err := NewGenerator(tmpfs, WithKeep("internal/pkg/")).Generate(ctx, ModifyGlob("**/*.go", replaceImport))
+		require.NoError(t, err)
+
+		assert.Equal(t, `import "example.com/new"`, string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, `import "example.com/new/a"`, string(tmpfs.MapFS["internal/a.go"].Data))
+		assert.Equal(t, `import "example.com/old/b"`, string(tmpfs.MapFS["internal/pkg/b.go"].Data))
+		assert.Equal(t, `import "example.com/old/vendor"`, string(tmpfs.MapFS["internal/pkg/vendor.go"].Data))
+		assert.Equal(t, `import "example.com/old/git"`, string(tmpfs.MapFS[".git/hooks/pre-commit.go"].Data))
+		assert.Equal(t, `import "example.com/old/dep"`, string(tmpfs.MapFS["web/node_modules/dep/index.go"].Data))
+	})
+
+	t.Run("No Matches", func(t *testing.T) {
+		tmpfs := newFS()
+
+		err := NewGenerator(tmpfs).Generate(ctx, ModifyGlob("**/*.ts", replaceImport))
+		require.NoError(t, err)
+
+		err = NewGenerator(tmpfs).Generate(ctx, ModifyGlob("**/*.ts", replaceImport).Required())
+		assert.ErrorIs(t, err, ErrNoMatches)
+	})
+}
@@ -0,0 +1,308 @@
+package drydock
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultGeneratedHeader follows the Go convention for generated files, see https://go.dev/s/generatedcode.
+const DefaultGeneratedHeader = "Code generated by drydock. DO NOT EDIT."
+
+// WithGeneratedHeader adds text as a comment at the top of every generated file, see [GeneratedHeader].
+// To be recognised by Go tooling and [IsGenerated], text should match `Code generated .* DO NOT EDIT.`
+func WithGeneratedHeader(text string) Option {
+	return func(g *Generator) {
+		g.generatedHeader = text
+	}
+}
+
+// GeneratedHeader adds the header set by [WithGeneratedHeader], or [DefaultGeneratedHeader] if there is none,
+// as a comment at the top of file. When file is a [Directory] the header is added to all of its entries.
+// The comment syntax depends on the file extension, files without a known syntax and binary files are left alone,
+// as are files that already start with the header. The header is placed after lines that must stay at the top,
+// like a shebang, an XML declaration, a doctype or the front matter of Markdown and HTML files, and is followed
+// by a blank line.
+// Modifications, see [ModifyFile], never get a header.
+func GeneratedHeader(file File) File {
+	if dir, ok := file.(Directory); ok {
+		return &headerDir{Directory: dir}
+	}
+
+	return &headerFile{File: file}
+}
+
+type headerFile struct {
+	File
+}
+
+// Unwrap returns the wrapped [File].
+func (f *headerFile) Unwrap() File {
+	return f.File
+}
+
+func (f *headerFile) generatedHeader() {}
+
+type headerDir struct {
+	Directory
+}
+
+// Unwrap returns the wrapped [Directory].
+func (d *headerDir) Unwrap() File {
+	return d.Directory
+}
+
+func (d *headerDir) generatedHeader() {}
+
+type headerSetter interface {
+	Unwrap() File
+	generatedHeader()
+}
+
+// ReadGeneratedHeader returns the text of the comments at the top of a file, skipping lines like a shebang.
+// It returns false if there are none, the file is binary or the comment syntax of the file is unknown.
+func ReadGeneratedHeader(name string, contents []byte) (string, bool) {
+	style, ok := commentStyleFor(name)
+	if !ok || isBinary(contents) {
+		return "", false
+	}
+
+	var text []string
+	for _, line := range strings.SplitAfter(string(contents[headerOffset(name, contents):]), "\n") {
+		uncommented, ok := style.uncomment(line)
+		if !ok {
+			break
+		}
+
+		text = append(text, uncommented)
+	}
+
+	if len(text) == 0 {
+		return "", false
+	}
+
+	return strings.Join(text, "\n"), true
+}
+
+var generatedCodePattern = regexp.MustCompile(`(?m)^Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated reports whether the header of the file marks it as generated, following the Go convention
+// of a line matching `Code generated .* DO NOT EDIT.` [WithPrune] uses it to keep files whose header was removed.
+func IsGenerated(name string, contents []byte) bool {
+	header, ok := ReadGeneratedHeader(name, contents)
+	return ok && generatedCodePattern.MatchString(header)
+}
+
+// generatedHeaderText returns the header for the file or an empty string if it doesn't get one.
+func (r *run) generatedHeaderText(file string) string {
+	if r.generatedHeader != "" {
+		return r.generatedHeader
+	}
+
+	for p := file; ; p = path.Dir(p) {
+		if r.headers[p] {
+			return DefaultGeneratedHeader
+		}
+
+		if p == "." || p == "/" {
+			return ""
+		}
+	}
+}
+
+// addGeneratedHeader wraps write to add the header to the generated contents.
+func addGeneratedHeader(file string, text string, write func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		var b bytes.Buffer
+		err := write(&b)
+		if err != nil {
+			return err
+		}
+
+		contents := b.Bytes()
+		if header, ok := ReadGeneratedHeader(file, contents); !ok || !strings.Contains(header, text) {
+			contents = insertHeader(file, contents, text)
+		}
+
+		_, err = w.Write(contents)
+		return err
+	}
+}
+
+// insertHeader adds text as a comment at the top of contents, unless the file is binary or has no known comment syntax.
+func insertHeader(name string, contents []byte, text string) []byte {
+	style, ok := commentStyleFor(name)
+	if !ok || isBinary(contents) {
+		return contents
+	}
+
+	nl := "\n"
+	if bytes.Contains(contents, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+
+	offset := headerOffset(name, contents)
+
+	var b bytes.Buffer
+	b.Write(contents[:offset])
+	if offset > 0 && contents[offset-1] != '\n' {
+		b.WriteString(nl)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(style.comment(line))
+		b.WriteString(nl)
+	}
+
+	b.WriteString(nl)
+	b.Write(contents[offset:])
+
+	return b.Bytes()
+}
+
+var pythonEncodingPattern = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=]`)
+
+// headerOffset returns the offset after the lines that must stay at the top of the file: front matter,
+// a shebang, an XML declaration, a doctype, a PHP open tag or a Python encoding declaration.
+func headerOffset(name string, contents []byte) int {
+	offset := frontMatterEnd(name, contents)
+	for i := 0; ; i++ {
+		rest := contents[offset:]
+
+		line := rest
+		if end := bytes.IndexByte(rest, '\n'); end != -1 {
+			line = rest[:end+1]
+		}
+
+		trimmed := strings.ToLower(strings.TrimSpace(string(line)))
+
+		switch {
+		case len(line) == 0:
+			return offset
+		case i == 0 && offset == 0 && strings.HasPrefix(trimmed, "#!"),
+			strings.HasPrefix(trimmed, "<?xml"),
+			strings.HasPrefix(trimmed, "<?php"),
+			strings.HasPrefix(trimmed, "<!doctype"),
+			i < 2 && pythonEncodingPattern.Match(line):
+			offset += len(line)
+		default:
+			return offset
+		}
+	}
+}
+
+// frontMatterExts are the extensions of files that can start with a front matter block, as used by static site
+// generators. YAML files are not included, as their `---` lines separate documents.
+var frontMatterExts = map[string]bool{".md": true, ".markdown": true, ".html": true, ".htm": true}
+
+// frontMatterEnd returns the offset after the front matter block delimited by `---` lines at the start of
+// the file, or 0 if there is none.
+func frontMatterEnd(name string, contents []byte) int {
+	if !frontMatterExts[strings.ToLower(path.Ext(name))] {
+		return 0
+	}
+
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	if len(lines) < 2 || string(bytes.TrimSpace(lines[0])) != "---" {
+		return 0
+	}
+
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		offset += len(line)
+
+		if trimmed := string(bytes.TrimSpace(line)); trimmed == "---" || trimmed == "..." {
+			return offset
+		}
+	}
+
+	return 0
+}
+
+// isBinary reports whether contents look like a binary file, i.e. contain a NUL byte or invalid UTF-8.
+func isBinary(contents []byte) bool {
+	return bytes.IndexByte(contents, 0) != -1 || !utf8.Valid(contents)
+}
+
+type commentStyle struct {
+	prefix string
+	suffix string
+}
+
+var (
+	slashComment = commentStyle{prefix: "//"}
+	hashComment  = commentStyle{prefix: "#"}
+	dashComment  = commentStyle{prefix: "--"}
+	blockComment = commentStyle{prefix: "/*", suffix: "*/"}
+	xmlComment   = commentStyle{prefix: "<!--", suffix: "-->"}
+)
+
+var commentStylesByExt = map[string]commentStyle{
+	".go": slashComment, ".js": slashComment, ".mjs": slashComment, ".cjs": slashComment, ".jsx": slashComment,
+	".ts": slashComment, ".mts": slashComment, ".cts": slashComment, ".tsx": slashComment,
+	".java": slashComment, ".kt": slashComment, ".kts": slashComment, ".scala": slashComment, ".groovy": slashComment,
+	".cpp": slashComment, ".cc": slashComment, ".hpp": slashComment, ".cs": slashComment, ".rs": slashComment,
+	".swift": slashComment, ".dart": slashComment, ".proto": slashComment, ".php": slashComment, ".zig": slashComment,
+
+	".sh": hashComment, ".bash": hashComment, ".zsh": hashComment, ".fish": hashComment, ".py": hashComment,
+	".rb": hashComment, ".pl": hashComment, ".r": hashComment, ".ps1": hashComment, ".yaml": hashComment,
+	".yml": hashComment, ".toml": hashComment, ".tf": hashComment, ".hcl": hashComment, ".cmake": hashComment,
+	".mk": hashComment, ".conf": hashComment, ".env": hashComment, ".gitignore": hashComment,
+	".dockerignore": hashComment, ".editorconfig": hashComment,
+
+	".sql": dashComment, ".lua": dashComment, ".hs": dashComment,
+
+	".c": blockComment, ".h": blockComment, ".css": blockComment, ".scss": blockComment, ".less": blockComment,
+
+	".html": xmlComment, ".htm": xmlComment, ".xhtml": xmlComment, ".xml": xmlComment, ".svg": xmlComment,
+	".md": xmlComment, ".markdown": xmlComment, ".vue": xmlComment, ".svelte": xmlComment,
+}
+
+var commentStylesByName = map[string]commentStyle{
+	"Makefile":      hashComment,
+	"Dockerfile":    hashComment,
+	"Containerfile": hashComment,
+	"Justfile":      hashComment,
+	"justfile":      hashComment,
+	"Gemfile":       hashComment,
+	"Rakefile":      hashComment,
+}
+
+func commentStyleFor(name string) (commentStyle, bool) {
+	base := path.Base(name)
+	if style, ok := commentStylesByName[base]; ok {
+		return style, true
+	}
+
+	style, ok := commentStylesByExt[strings.ToLower(path.Ext(base))]
+	return style, ok
+}
+
+func (s commentStyle) comment(line string) string {
+	if line == "" && s.suffix == "" {
+		return s.prefix
+	}
+
+	if s.suffix == "" {
+		return s.prefix + " " + line
+	}
+
+	return s.prefix + " " + line + " " + s.suffix
+}
+
+func (s commentStyle) uncomment(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	if !strings.HasPrefix(line, s.prefix) || !strings.HasSuffix(line, s.suffix) || len(line) < len(s.prefix)+len(s.suffix) {
+		return "", false
+	}
+
+	line = line[len(s.prefix) : len(line)-len(s.suffix)]
+	line = strings.TrimPrefix(line, " ")
+	line = strings.TrimSuffix(line, " ")
+
+	return line, true
+}
@@ -0,0 +1,118 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_GeneratedHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("WithGeneratedHeader", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"config.yaml": &fstest.MapFile{Data: []byte("foo: bar\n")},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithGeneratedHeader("Code generated by blueprint. DO NOT EDIT.")).Generate(ctx,
+			PlainFile("main.go", "package main\n"),
+			PlainFile("run.sh", "#!/bin/sh\necho hello\n"),
+			PlainFile("data.json", `{"foo": "bar"}`),
+			PlainFile("logo.png", "\x89PNG\r\n\x1a\n\x00\x00"),
+			ModifyFile("config.yaml", func(contents []byte, w io.Writer) error {
+				_, err := w.Write(append(contents, "baz: bat\n"...))
+				return err
+			}),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, "// Code generated by blueprint. DO NOT EDIT.\n\npackage main\n", string(tmpfs.MapFS["main.go"].Data))
+		assert.Equal(t, "#!/bin/sh\n# Code generated by blueprint. DO NOT EDIT.\n\necho hello\n", string(tmpfs.MapFS["run.sh"].Data))
+		assert.Equal(t, `{"foo": "bar"}`, string(tmpfs.MapFS["data.json"].Data))
+		assert.Equal(t, "\x89PNG\r\n\x1a\n\x00\x00", string(tmpfs.MapFS["logo.png"].Data))
+		assert.Equal(t, "foo: bar\nbaz: bat\n", string(tmpfs.MapFS["config.yaml"].Data))
+	})
+
+	t.Run("GeneratedHeader", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs).Generate(ctx,
+			GeneratedHeader(Dir("gen", PlainFile("types.ts", "export type ID = string;\n"))),
+			PlainFile("main.go", "package main\n"),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, "// Code generated by drydock. DO NOT EDIT.\n\nexport type ID = string;\n", string(tmpfs.MapFS["gen/types.ts"].Data))
+		assert.Equal(t, "package main\n", string(tmpfs.MapFS["main.go"].Data))
+	})
+
+	t.Run("Existing Header", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		contents := "// Code generated by drydock. DO NOT EDIT.\n\npackage main\n"
+
+		err := NewGenerator(tmpfs).Generate(ctx, GeneratedHeader(PlainFile("main.go", contents)))
+		require.NoError(t, err)
+
+		assert.Equal(t, contents, string(tmpfs.MapFS["main.go"].Data))
+	})
+}
+
+func TestInsertHeader(t *testing.T) {
+	tt := []struct {
+		name     string
+		contents string
+		text     string
+		exp      string
+	}{
+		{name: "main.go", contents: "package main\n", text: "line 1\n\nline 2", exp: "// line 1\n//\n// line 2\n\npackage main\n"},
+		{name: "Makefile", contents: "all:\n", text: "header", exp: "# header\n\nall:\n"},
+		{name: "schema.sql", contents: "SELECT 1;\n", text: "header", exp: "-- header\n\nSELECT 1;\n"},
+		{name: "style.css", contents: "body {}\n", text: "header", exp: "/* header */\n\nbody {}\n"},
+		{name: "index.html", contents: "<!DOCTYPE html>\n<html></html>\n", text: "header", exp: "<!DOCTYPE html>\n<!-- header -->\n\n<html></html>\n"},
+		{name: "pom.xml", contents: "<?xml version=\"1.0\"?>\n<project/>\n", text: "header", exp: "<?xml version=\"1.0\"?>\n<!-- header -->\n\n<project/>\n"},
+		{name: "index.php", contents: "<?php\necho 1;\n", text: "header", exp: "<?php\n// header\n\necho 1;\n"},
+		{name: "app.py", contents: "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\nprint(1)\n", text: "header", exp: "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\n# header\n\nprint(1)\n"},
+		{name: "run.sh", contents: "#!/bin/sh", text: "header", exp: "#!/bin/sh\n# header\n\n"},
+		{name: "main.go", contents: "package main\r\n", text: "header", exp: "// header\r\n\r\npackage main\r\n"},
+		{name: "empty.go", contents: "", text: "header", exp: "// header\n\n"},
+		{name: "post.md", contents: "---\ntitle: Post\n---\n# Post\n", text: "header", exp: "---\ntitle: Post\n---\n<!-- header -->\n\n# Post\n"},
+		{name: "page.html", contents: "---\nlayout: page\n...\n<p></p>\n", text: "header", exp: "---\nlayout: page\n...\n<!-- header -->\n\n<p></p>\n"},
+		{name: "open.md", contents: "---\n# Post\n", text: "header", exp: "<!-- header -->\n\n---\n# Post\n"},
+		{name: "config.yaml", contents: "---\nfoo: bar\n---\nbaz: bat\n", text: "header", exp: "# header\n\n---\nfoo: bar\n---\nbaz: bat\n"},
+		{name: "data.json", contents: "{}", text: "header", exp: "{}"},
+		{name: "binary.go", contents: "\x00\x01", text: "header", exp: "\x00\x01"},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, string(insertHeader(tt.name, []byte(tt.contents), tt.text)))
+		})
+	}
+}
+
+func TestReadGeneratedHeader(t *testing.T) {
+	header, ok := ReadGeneratedHeader("main.go", []byte("// Code generated by drydock. DO NOT EDIT.\n//\n// more\n\npackage main\n"))
+	assert.True(t, ok)
+	assert.Equal(t, "Code generated by drydock. DO NOT EDIT.\n\nmore", header)
+
+	header, ok = ReadGeneratedHeader("index.html", []byte("<!DOCTYPE html>\r\n<!-- Code generated by drydock. DO NOT EDIT. -->\r\n\r\n<html></html>"))
+	assert.True(t, ok)
+	assert.Equal(t, "Code generated by drydock. DO NOT EDIT.", header)
+
+	_, ok = ReadGeneratedHeader("main.go", []byte("package main\n"))
+	assert.False(t, ok)
+
+	_, ok = ReadGeneratedHeader("data.json", []byte("// Code generated by drydock. DO NOT EDIT.\n"))
+	assert.False(t, ok)
+
+	assert.True(t, IsGenerated("run.sh", insertHeader("run.sh", []byte("#!/bin/sh\necho\n"), DefaultGeneratedHeader)))
+	assert.True(t, IsGenerated("style.css", insertHeader("style.css", []byte("body {}\n"), DefaultGeneratedHeader)))
+	assert.False(t, IsGenerated("main.go", []byte("// Package main does things.\npackage main\n")))
+	assert.True(t, IsGenerated("post.md", insertHeader("post.md", []byte("---\ntitle: Post\n---\n# Post\n"), DefaultGeneratedHeader)))
+}
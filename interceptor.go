@@ -0,0 +1,105 @@
+package drydock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrRejected is returned by interceptors created with [RejectOutside] and should be wrapped
+// by custom interceptors that veto an operation.
+var ErrRejected = errors.New("rejected by interceptor")
+
+// FileOp is a pending write of a file to the staging area, passed to every [Interceptor].
+type FileOp struct {
+	// Path of the file relative to the output root. Changing it moves the file.
+	Path string
+	// Modification is true if the file is a modification of an existing file, see [ModifyFile].
+	Modification bool
+	// Write writes the contents of the file to w. It can be wrapped to transform the contents.
+	Write func(w io.Writer) error
+}
+
+// Interceptor is called for every file before it is written to the staging area and can pass the [FileOp] on unchanged,
+// transform its contents by wrapping [FileOp.Write], rename it by changing [FileOp.Path] or reject it by returning an error.
+type Interceptor func(ctx context.Context, op *FileOp) error
+
+// WithInterceptor adds interceptors to the chain that is run for every generated or modified file, in the order they were added.
+func WithInterceptor(interceptors ...Interceptor) Option {
+	return func(g *Generator) {
+		g.interceptors = append(g.interceptors, interceptors...)
+	}
+}
+
+// RejectOutside returns an [Interceptor] that rejects all files outside of the given directories.
+func RejectOutside(dirs ...string) Interceptor {
+	return func(_ context.Context, op *FileOp) error {
+		for _, dir := range dirs {
+			dir = path.Clean(dir)
+			if dir == "." || strings.HasPrefix(op.Path, dir+"/") {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w: %s is outside of %s", ErrRejected, op.Path, strings.Join(dirs, ", "))
+	}
+}
+
+// RenameFunc returns an [Interceptor] that renames every file to the path returned by rename.
+func RenameFunc(rename func(p string) string) Interceptor {
+	return func(_ context.Context, op *FileOp) error {
+		op.Path = rename(op.Path)
+		return nil
+	}
+}
+
+// TransformFunc returns an [Interceptor] that replaces the contents of every file with the result of transform.
+// Modifications of existing files are left untouched, unless includeModifications is set.
+func TransformFunc(includeModifications bool, transform func(p string, contents []byte) ([]byte, error)) Interceptor {
+	return func(_ context.Context, op *FileOp) error {
+		if op.Modification && !includeModifications {
+			return nil
+		}
+
+		write := op.Write
+		op.Write = func(w io.Writer) error {
+			var b bytes.Buffer
+			err := write(&b)
+			if err != nil {
+				return err
+			}
+
+			transformed, err := transform(op.Path, b.Bytes())
+			if err != nil {
+				return err
+			}
+
+			_, err = w.Write(transformed)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (g *Generator) intercept(ctx context.Context, op *FileOp) error {
+	for _, interceptor := range g.interceptors {
+		err := interceptor(ctx, op)
+		if err != nil {
+			return err
+		}
+	}
+
+	cleaned := path.Clean(op.Path)
+	if op.Path == "" || cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("invalid path '%s'", op.Path)
+	}
+
+	op.Path = cleaned
+
+	return nil
+}
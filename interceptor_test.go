@@ -0,0 +1,78 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Interceptor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("Transform and Rename", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"internal/existing.go": &fstest.MapFile{Data: []byte("package internal")},
+		}, baseDir: "."}
+
+		addHeader := TransformFunc(false, func(p string, contents []byte) ([]byte, error) {
+			if path.Ext(p) != ".go" {
+				return contents, nil
+			}
+			return append([]byte("// Copyright ACME Corp.\n"), contents...), nil
+		})
+
+		renameMakefile := RenameFunc(func(p string) string {
+			if path.Base(p) == "Makefile" {
+				return path.Join(path.Dir(p), "build", "justfile")
+			}
+			return p
+		})
+
+		g := NewGenerator(tmpfs, WithInterceptor(addHeader, renameMakefile))
+		err := g.Generate(ctx,
+			Dir("internal",
+				PlainFile("main.go", "package internal"),
+				PlainFile("Makefile", "build:"),
+				ModifyFile("existing.go", func(contents []byte, w io.Writer) error {
+					_, err := w.Write(append(contents, []byte("\n// modified")...))
+					return err
+				}),
+			),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, "// Copyright ACME Corp.\npackage internal", string(tmpfs.MapFS["internal/main.go"].Data))
+		assert.Equal(t, "build:", string(tmpfs.MapFS["internal/build/justfile"].Data))
+		assert.NotContains(t, tmpfs.MapFS, "internal/Makefile")
+		assert.Equal(t, "package internal\n// modified", string(tmpfs.MapFS["internal/existing.go"].Data))
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithInterceptor(RejectOutside("internal")))
+		err := g.Generate(ctx,
+			Dir("internal", PlainFile("main.go", "package internal")),
+			PlainFile("README.md", "# drydock"),
+		)
+		require.ErrorIs(t, err, ErrRejected)
+		assert.NotContains(t, tmpfs.MapFS, "internal/main.go")
+	})
+
+	t.Run("Invalid Path", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithInterceptor(RenameFunc(func(p string) string {
+			return "../" + p
+		})))
+		err := g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "invalid path")
+	})
+}
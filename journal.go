@@ -0,0 +1,474 @@
+package drydock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// JournalDir is the directory, relative to the output root, where the [Generator] keeps the journal
+// of the commit in progress when [WithJournal] is enabled.
+const JournalDir = ".drydock/journal"
+
+const tempDirPattern = "drydock-*"
+
+// tempDirOwnerFile records the PID and hostname of the generator using a staging dir.
+const tempDirOwnerFile = ".drydock-owner"
+
+// ErrIncompleteCommit is returned by [Generator.Generate] when the journal of an interrupted commit is found.
+// Use [Generator.Recover] to finish or roll back the commit.
+var ErrIncompleteCommit = errors.New("incomplete commit found")
+
+// RecoveryAction describes what [Generator.Recover] did.
+type RecoveryAction int
+
+const (
+	// RecoveryNone means no interrupted commit was found.
+	RecoveryNone RecoveryAction = iota
+	// RecoveryRolledForward means the interrupted commit was finished.
+	RecoveryRolledForward
+	// RecoveryRolledBack means the changes of the interrupted commit were reverted.
+	RecoveryRolledBack
+)
+
+func (a RecoveryAction) String() string {
+	switch a {
+	case RecoveryNone:
+		return "none"
+	case RecoveryRolledForward:
+		return "rolled forward"
+	case RecoveryRolledBack:
+		return "rolled back"
+	default:
+		return fmt.Sprintf("RecoveryAction(%d)", int(a))
+	}
+}
+
+// WithJournal writes a journal of all planned renames to [JournalDir] before files are moved into the output
+// and marks every completed step. If the commit fails, the completed steps are rolled back. If the process is killed
+// during the commit, [Generator.Recover] uses the journal to finish or roll back the commit.
+// Previous contents of overwritten files are kept in the journal until the commit is complete.
+// With [WithSync] the journal is synced after every entry.
+func WithJournal(b bool) Option {
+	return func(g *Generator) {
+		g.journal = b
+	}
+}
+
+// journalEntry is a single line of the journal. Exactly one field is set.
+type journalEntry struct {
+	TmpDir string        `json:"tmpdir,omitempty"`
+	Steps  []journalStep `json:"steps,omitempty"`
+	Done   *int          `json:"done,omitempty"`
+}
+
+type journalStep struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Existed bool   `json:"existed"`
+	// Dir is set if a whole dir that didn't exist before is moved.
+	Dir bool `json:"dir,omitempty"`
+}
+
+type journalState struct {
+	tmpdir string
+	steps  []journalStep
+	done   map[int]bool
+}
+
+type journalWriter struct {
+	f fs.File
+	w io.Writer
+}
+
+// syncJournal flushes the journal to stable storage when [WithSync] is enabled, so the journal is durable
+// before the step it describes is carried out.
+func (r *run) syncJournal() error {
+	if !r.sync {
+		return nil
+	}
+
+	if syncer, ok := r.journalWriter.f.(interface{ Sync() error }); ok {
+		err := syncer.Sync()
+		if err != nil {
+			return fmt.Errorf("error syncing journal: %w", err)
+		}
+
+		return nil
+	}
+
+	return r.syncFile(journalPath())
+}
+
+func journalPath() string {
+	return path.Join(JournalDir, "journal")
+}
+
+func journalPreimagePath(file string) string {
+	return path.Join(JournalDir, "preimages", file)
+}
+
+// beginJournal creates a new journal for the staging dir.
+func (r *run) beginJournal() error {
+	if !r.journal {
+		return nil
+	}
+
+	err := mkdirAll(r.output, JournalDir)
+	if err != nil {
+		return fmt.Errorf("error creating journal: %w", err)
+	}
+
+	f, err := r.output.OpenFile(journalPath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating journal: %w", err)
+	}
+
+	w, ok := f.(io.Writer)
+	if !ok {
+		return errors.Join(fmt.Errorf("file %s opened with FS %T is not io.Writer", journalPath(), r.output), f.Close())
+	}
+
+	r.journalWriter = &journalWriter{f: f, w: w}
+
+	err = r.appendJournal(journalEntry{TmpDir: r.tmpdir})
+	if err != nil {
+		return err
+	}
+
+	return r.syncFile(JournalDir)
+}
+
+func (r *run) appendJournal(entry journalEntry) error {
+	if r.journalWriter == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	_, err = r.journalWriter.w.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	return r.syncJournal()
+}
+
+func (r *run) journalPlan(steps []journalStep) error {
+	return r.appendJournal(journalEntry{Steps: steps})
+}
+
+// journalPreimage keeps the current contents of the file in the journal before it is overwritten.
+func (r *run) journalPreimage(file string) error {
+	if r.journalWriter == nil {
+		return nil
+	}
+
+	// the preimage is written to a temporary file first, so a partially written preimage is never restored
+	preimage := journalPreimagePath(file)
+
+	err := copyFile(r.output, file, preimage+".tmp")
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	err = r.syncFile(preimage + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = r.output.Rename(preimage+".tmp", preimage)
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	return r.syncFile(path.Dir(preimage))
+}
+
+func (r *run) journalDone(i int) error {
+	return r.appendJournal(journalEntry{Done: &i})
+}
+
+// finishJournal removes the journal after a successful commit.
+// On failure the commit is rolled back and the journal is removed as well.
+func (r *run) finishJournal(ctx context.Context, commitErr error) error {
+	if r.journalWriter == nil {
+		return commitErr
+	}
+
+	err := r.journalWriter.f.Close()
+	r.journalWriter = nil
+	if err != nil {
+		return errors.Join(commitErr, fmt.Errorf("error writing journal: %w", err))
+	}
+
+	if commitErr != nil {
+		state, err := readJournal(r.output)
+		if err != nil {
+			return errors.Join(commitErr, err)
+		}
+
+		err = rollbackJournal(ctx, r.output, state)
+		if err != nil {
+			// the journal is kept, so the rollback can be retried with Recover
+			return errors.Join(commitErr, err)
+		}
+
+		return errors.Join(commitErr, removeJournal(r.output))
+	}
+
+	return removeJournal(r.output)
+}
+
+func removeJournal(output OutputFS) error {
+	err := output.RemoveAll(JournalDir)
+	if err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
+
+	return removeIfEmpty(output, path.Dir(JournalDir))
+}
+
+func hasJournal(output OutputFS) (bool, error) {
+	return fileExists(output, journalPath())
+}
+
+func readJournal(output OutputFS) (*journalState, error) {
+	data, err := fs.ReadFile(output, journalPath())
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+
+	state := &journalState{done: map[int]bool{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var entry journalEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			// a partially written last line is the result of the interruption
+			break
+		}
+
+		switch {
+		case entry.TmpDir != "":
+			state.tmpdir = entry.TmpDir
+		case entry.Steps != nil:
+			state.steps = entry.Steps
+		case entry.Done != nil:
+			state.done[*entry.Done] = true
+		}
+	}
+
+	return state, nil
+}
+
+// Recover finishes or rolls back a commit that was interrupted, e.g. because the process was killed, using the journal
+// written when [WithJournal] is enabled. The commit is finished if all staged files still exist, otherwise all completed
+// steps are reverted. Afterwards orphaned staging dirs of earlier runs are removed, if the output implements [TempDirFS].
+func (g *Generator) Recover(ctx context.Context) (RecoveryAction, error) {
+	unlock, err := g.acquireLock(ctx)
+	if err != nil {
+		return RecoveryNone, err
+	}
+	defer unlock() //nolint: errcheck
+
+	action, err := recoverJournal(ctx, g.output)
+	if err != nil {
+		return action, err
+	}
+
+	return action, removeOrphanedTempDirs(g.output)
+}
+
+func recoverJournal(ctx context.Context, output OutputFS) (RecoveryAction, error) {
+	exists, err := hasJournal(output)
+	if err != nil || !exists {
+		return RecoveryNone, err
+	}
+
+	state, err := readJournal(output)
+	if err != nil {
+		return RecoveryNone, err
+	}
+
+	if state.steps != nil && canRollForward(output, state) {
+		err = rollForwardJournal(ctx, output, state)
+		if err != nil {
+			return RecoveryNone, err
+		}
+
+		return RecoveryRolledForward, errors.Join(output.RemoveAll(state.tmpdir), removeJournal(output))
+	}
+
+	err = rollbackJournal(ctx, output, state)
+	if err != nil {
+		return RecoveryNone, err
+	}
+
+	return RecoveryRolledBack, errors.Join(output.RemoveAll(state.tmpdir), removeJournal(output))
+}
+
+// canRollForward reports whether the staged files of all pending steps still exist.
+func canRollForward(output OutputFS, state *journalState) bool {
+	tempDirFS, ok := output.(TempDirFS)
+	if !ok {
+		return false
+	}
+
+	staging, err := tempDirFS.OpenTempDir(state.tmpdir)
+	if err != nil {
+		return false
+	}
+
+	for i, step := range state.steps {
+		if state.done[i] {
+			continue
+		}
+
+		exists, err := fileExists(staging, strings.TrimPrefix(step.From, state.tmpdir+"/"))
+		if err != nil {
+			return false
+		}
+
+		// the step that was in progress might have completed without being marked as done
+		if !exists && !isInFlight(state, i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isInFlight reports whether step i is the first step that hasn't been marked as done.
+func isInFlight(state *journalState, i int) bool {
+	for j := range i {
+		if !state.done[j] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func rollForwardJournal(ctx context.Context, output OutputFS, state *journalState) error {
+	for i, step := range state.steps {
+		if state.done[i] {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		err := output.Rename(step.From, step.To)
+		if err != nil && !(errors.Is(err, fs.ErrNotExist) && isInFlight(state, i)) {
+			return fmt.Errorf("error finishing commit of %s: %w", step.To, err)
+		}
+	}
+
+	return nil
+}
+
+func rollbackJournal(ctx context.Context, output OutputFS, state *journalState) error {
+	for i := len(state.steps) - 1; i >= 0; i-- {
+		step := state.steps[i]
+		if !state.done[i] && !isInFlight(state, i) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var err error
+		if step.Existed {
+			err = copyFile(output, journalPreimagePath(step.To), step.To)
+			if errors.Is(err, fs.ErrNotExist) && !state.done[i] {
+				// the preimage wasn't written yet, so the file wasn't replaced either
+				err = nil
+			}
+		} else if step.Dir {
+			err = output.RemoveAll(step.To)
+		} else if state.done[i] || isInFlight(state, i) {
+			err = output.Remove(step.To)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("error rolling back %s: %w", step.To, err)
+		}
+	}
+
+	return nil
+}
+
+// writeTempDirOwner marks the staging dir as used by the current process.
+func (r *run) writeTempDirOwner() error {
+	err := writeFile(r.tmptfs, tempDirOwnerFile, []byte(currentLockOwner().String()))
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+
+	return nil
+}
+
+// removeOrphanedTempDirs removes staging dirs whose owner file names a process on this host that is no longer
+// running. Staging dirs without owner file might belong to another program and are never removed.
+func removeOrphanedTempDirs(output OutputFS) error {
+	tempDirFS, ok := output.(TempDirFS)
+	if !ok {
+		return nil
+	}
+
+	dirs, err := tempDirFS.TempDirs(tempDirPattern)
+	if err != nil {
+		return fmt.Errorf("error listing temporary directories: %w", err)
+	}
+
+	for _, dir := range dirs {
+		staging, err := tempDirFS.OpenTempDir(dir)
+		if err != nil {
+			continue
+		}
+
+		if !isOrphanedTempDir(staging) {
+			continue
+		}
+
+		err = output.RemoveAll(dir)
+		if err != nil {
+			return fmt.Errorf("error removing orphaned temporary directory '%s': %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+func isOrphanedTempDir(staging fs.FS) bool {
+	data, err := fs.ReadFile(staging, tempDirOwnerFile)
+	if err != nil {
+		return false
+	}
+
+	owner, ok := parseLockOwner(data)
+	return ok && owner.dead()
+}
@@ -0,0 +1,201 @@
+package drydock
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Journal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("Success", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"README.md": &fstest.MapFile{Data: []byte("old readme")},
+		}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithJournal(true), WithErrorOnExistingFile(false))
+		err := g.Generate(ctx, PlainFile("README.md", "new readme"), Dir("cmd", PlainFile("main.go", "package main")))
+		require.NoError(t, err)
+
+		assert.Equal(t, "new readme", string(tmpfs.MapFS["README.md"].Data))
+		assert.Equal(t, "package main", string(tmpfs.MapFS["cmd/main.go"].Data))
+		assertNotExists(t, tmpfs, JournalDir)
+		assertNotExists(t, tmpfs, ".drydock")
+	})
+
+	t.Run("Rollback on Failure", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"README.md": &fstest.MapFile{Data: []byte("old readme")},
+		}, baseDir: "."}
+
+		output := &failingRenameFS{MapFSOutputFS: tmpfs, fail: "z.txt"}
+
+		g := NewGenerator(output, WithJournal(true), WithErrorOnExistingFile(false))
+		err := g.Generate(ctx,
+			PlainFile("README.md", "new readme"),
+			Dir("cmd", PlainFile("main.go", "package main")),
+			PlainFile("z.txt", "z"),
+		)
+		require.ErrorIs(t, err, fs.ErrPermission)
+
+		assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+		assertNotExists(t, tmpfs, "cmd/main.go")
+		assertNotExists(t, tmpfs, "cmd")
+		assertNotExists(t, tmpfs, "z.txt")
+		assertNotExists(t, tmpfs, JournalDir)
+	})
+
+	t.Run("Incomplete Commit", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			path.Join(JournalDir, "journal"): &fstest.MapFile{Data: []byte(`{"tmpdir":"/tmp/drydock-incomplete"}` + "\n")},
+		}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithJournal(true))
+		err := g.Generate(ctx, PlainFile("README.md", "readme"))
+		require.ErrorIs(t, err, ErrIncompleteCommit)
+		assertNotExists(t, tmpfs, "README.md")
+	})
+
+	t.Run("OSOutputFS removes staging dir", func(t *testing.T) {
+		output := &tempDirRecordingFS{OutputFS: NewOSOutputFS(t.TempDir())}
+
+		err := NewGenerator(output, WithJournal(true)).Generate(ctx, PlainFile("README.md", "readme"))
+		require.NoError(t, err)
+
+		require.Len(t, output.tmpdirs, 1)
+		_, err = os.Stat(output.tmpdirs[0])
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func TestGenerator_Recover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	journal := `{"tmpdir":"/tmp/drydock-interrupted"}
+{"steps":[{"from":"/tmp/drydock-interrupted/README.md","to":"README.md","existed":true},{"from":"/tmp/drydock-interrupted/cmd","to":"cmd","existed":false,"dir":true},{"from":"/tmp/drydock-interrupted/LICENSE","to":"LICENSE","existed":false}]}
+{"done":0}
+{"do`
+
+	interrupted := func() fstest.MapFS {
+		return fstest.MapFS{
+			"README.md":                        &fstest.MapFile{Data: []byte("new readme")},
+			path.Join(JournalDir, "journal"):   &fstest.MapFile{Data: []byte(journal)},
+			journalPreimagePath("README.md"):   &fstest.MapFile{Data: []byte("old readme")},
+			"/tmp/drydock-interrupted":         &fstest.MapFile{Mode: 0o755 | os.ModeDir},
+			"cmd":                              &fstest.MapFile{Mode: 0o755 | os.ModeDir},
+			"cmd/main.go":                      &fstest.MapFile{Data: []byte("package main")},
+			"/tmp/drydock-interrupted/LICENSE": &fstest.MapFile{Data: []byte("license")},
+		}
+	}
+
+	t.Run("Roll Forward", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: interrupted(), baseDir: "."}
+
+		action, err := NewGenerator(tmpfs).Recover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, RecoveryRolledForward, action)
+
+		assert.Equal(t, "new readme", string(tmpfs.MapFS["README.md"].Data))
+		assert.Equal(t, "package main", string(tmpfs.MapFS["cmd/main.go"].Data))
+		assert.Equal(t, "license", string(tmpfs.MapFS["LICENSE"].Data))
+		assertNotExists(t, tmpfs, JournalDir)
+		assertNotExists(t, tmpfs, "/tmp/drydock-interrupted")
+	})
+
+	t.Run("Roll Back", func(t *testing.T) {
+		// the staging dir is gone, e.g. because it was removed on reboot
+		mapfs := interrupted()
+		for p := range mapfs {
+			if strings.HasPrefix(p, "/tmp/drydock-interrupted") {
+				delete(mapfs, p)
+			}
+		}
+		tmpfs := &MapFSOutputFS{MapFS: mapfs, baseDir: "."}
+
+		action, err := NewGenerator(tmpfs).Recover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, RecoveryRolledBack, action)
+
+		assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+		assertNotExists(t, tmpfs, "cmd")
+		assertNotExists(t, tmpfs, "LICENSE")
+		assertNotExists(t, tmpfs, JournalDir)
+	})
+
+	t.Run("Nothing to Recover", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		action, err := NewGenerator(tmpfs).Recover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, RecoveryNone, action)
+	})
+
+	t.Run("Orphaned Temp Dirs", func(t *testing.T) {
+		dead := currentLockOwner()
+		dead.pid = 1<<22 + 1
+
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"/tmp/drydock-dead": &fstest.MapFile{Mode: 0o755 | os.ModeDir},
+			path.Join("/tmp/drydock-dead", tempDirOwnerFile):  &fstest.MapFile{Data: []byte(dead.String())},
+			"/tmp/drydock-dead/README.md":                     &fstest.MapFile{Data: []byte("readme")},
+			"/tmp/drydock-alive":                              &fstest.MapFile{Mode: 0o755 | os.ModeDir},
+			path.Join("/tmp/drydock-alive", tempDirOwnerFile): &fstest.MapFile{Data: []byte(currentLockOwner().String())},
+			"/tmp/drydock-unowned":                            &fstest.MapFile{Mode: 0o755 | os.ModeDir},
+			"/tmp/drydock-unowned/README.md":                  &fstest.MapFile{Data: []byte("readme")},
+		}, baseDir: "."}
+
+		action, err := NewGenerator(tmpfs).Recover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, RecoveryNone, action)
+
+		assertNotExists(t, tmpfs, "/tmp/drydock-dead")
+		assertNotExists(t, tmpfs, "/tmp/drydock-dead/README.md")
+		assert.Contains(t, tmpfs.MapFS, "/tmp/drydock-alive")
+		assert.Contains(t, tmpfs.MapFS, "/tmp/drydock-unowned/README.md")
+	})
+}
+
+func assertNotExists(t *testing.T, tmpfs *MapFSOutputFS, name string) {
+	t.Helper()
+
+	for p := range tmpfs.MapFS {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			assert.Fail(t, fmt.Sprintf("expected %s not to exist", name), p)
+		}
+	}
+}
+
+type failingRenameFS struct {
+	*MapFSOutputFS
+	fail string
+}
+
+func (fsys *failingRenameFS) Rename(oldpath string, newpath string) error {
+	if newpath == fsys.fail {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrPermission}
+	}
+
+	return fsys.MapFSOutputFS.Rename(oldpath, newpath)
+}
+
+type tempDirRecordingFS struct {
+	OutputFS
+	tmpdirs []string
+}
+
+func (fsys *tempDirRecordingFS) MkdirTemp(pattern string) (OutputFS, string, error) {
+	tmpfs, tmpdir, err := fsys.OutputFS.MkdirTemp(pattern)
+	fsys.tmpdirs = append(fsys.tmpdirs, tmpdir)
+	return tmpfs, tmpdir, err
+}
@@ -0,0 +1,163 @@
+package drydock
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// KeepFile is the name of an optional file in the output root with one keep pattern per line,
+// in addition to those passed to [WithKeep]. Blank lines and lines starting with `#` are ignored.
+const KeepFile = ".drydockkeep"
+
+type keepPattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// keepList matches paths against gitignore-style patterns. Patterns without a slash match at any depth,
+// patterns with a slash are relative to the output root. `**` matches any number of directories,
+// a trailing `/` only matches directories and a leading `!` re-includes a previously matched path.
+type keepList struct {
+	patterns []keepPattern
+}
+
+func (kl *keepList) add(patterns ...string) {
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		var kp keepPattern
+
+		if strings.HasPrefix(p, "!") {
+			kp.negate = true
+			p = p[1:]
+		}
+
+		if strings.HasSuffix(p, "/") {
+			kp.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+
+		if !strings.Contains(p, "/") {
+			p = "**/" + p
+		}
+
+		kp.segments = strings.Split(strings.TrimPrefix(p, "/"), "/")
+
+		kl.patterns = append(kl.patterns, kp)
+	}
+}
+
+func (kl *keepList) parse(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		kl.add(scanner.Text())
+	}
+}
+
+func (kl *keepList) empty() bool {
+	return kl == nil || len(kl.patterns) == 0
+}
+
+// match reports whether the path should be kept, either because it matches itself
+// or because one of its parent directories does.
+func (kl *keepList) match(name string, isDir bool) bool {
+	if kl.empty() {
+		return false
+	}
+
+	segments := strings.Split(path.Clean(name), "/")
+
+	for i := 1; i < len(segments); i++ {
+		if kl.matchSegments(segments[:i], true) {
+			return true
+		}
+	}
+
+	return kl.matchSegments(segments, isDir)
+}
+
+func (kl *keepList) matchSegments(segments []string, isDir bool) bool {
+	matched := false
+	for _, p := range kl.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		if matchPatternSegments(p.segments, segments) {
+			matched = !p.negate
+		}
+	}
+
+	return matched
+}
+
+func matchPatternSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPatternSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	ok, err := path.Match(pattern[0], segments[0])
+	if err != nil || !ok {
+		return false
+	}
+
+	return matchPatternSegments(pattern[1:], segments[1:])
+}
+
+func (g *Generator) loadKeepFile() (*keepList, error) {
+	keep := &keepList{patterns: slices.Clone(g.keep.patterns)}
+	keep.add("/"+KeepFile, "/"+LockPath)
+
+	if g.backupDir != "" {
+		keep.add("/" + g.backupDir + "/")
+	}
+
+	if g.prune {
+		keep.add("/" + ManifestPath)
+	}
+
+	if g.undo {
+		keep.add("/" + UndoDir + "/")
+	}
+
+	if g.journal {
+		keep.add("/" + JournalDir + "/")
+	}
+
+	data, err := fs.ReadFile(g.output, KeepFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return keep, nil
+		}
+
+		return nil, fmt.Errorf("%w: error reading %s: %w", ErrCleaningOutputDir, KeepFile, err)
+	}
+
+	keep.parse(data)
+
+	return keep, nil
+}
@@ -0,0 +1,77 @@
+package drydock
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepList_Match(t *testing.T) {
+	tt := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		exp      bool
+	}{
+		{name: "Basename Any Depth", patterns: []string{"LOCAL_NOTES.md"}, path: "a/b/LOCAL_NOTES.md", exp: true},
+		{name: "Anchored", patterns: []string{"/LOCAL_NOTES.md"}, path: "a/LOCAL_NOTES.md", exp: false},
+		{name: "Anchored Root", patterns: []string{"/LOCAL_NOTES.md"}, path: "LOCAL_NOTES.md", exp: true},
+		{name: "Dir Only Matches Dir", patterns: []string{".git/"}, path: ".git", isDir: true, exp: true},
+		{name: "Dir Only Skips File", patterns: []string{".git/"}, path: ".git", isDir: false, exp: false},
+		{name: "Inside Kept Dir", patterns: []string{"node_modules/"}, path: "web/node_modules/react/index.js", exp: true},
+		{name: "Glob", patterns: []string{"*.local"}, path: "config/dev.local", exp: true},
+		{name: "Double Star Middle", patterns: []string{"docs/**/notes.txt"}, path: "docs/a/b/notes.txt", exp: true},
+		{name: "Double Star Zero Dirs", patterns: []string{"docs/**/notes.txt"}, path: "docs/notes.txt", exp: true},
+		{name: "Double Star Trailing", patterns: []string{"vendor/**"}, path: "vendor/x/y.go", exp: true},
+		{name: "Negation", patterns: []string{"*.md", "!README.md"}, path: "README.md", exp: false},
+		{name: "Negation Order", patterns: []string{"!README.md", "*.md"}, path: "README.md", exp: true},
+		{name: "Comment", patterns: []string{"# README.md"}, path: "README.md", exp: false},
+		{name: "No Match", patterns: []string{".idea/"}, path: "main.go", exp: false},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			var kl keepList
+			kl.add(tt.patterns...)
+			assert.Equal(t, tt.exp, kl.match(tt.path, tt.isDir))
+		})
+	}
+}
+
+func TestGenerator_Generate_EmptyOutputDir_Keep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		".git/HEAD":                   &fstest.MapFile{Data: []byte("ref: refs/heads/main")},
+		".idea/workspace.xml":         &fstest.MapFile{Data: []byte("<xml/>")},
+		"web/node_modules/react/x.js": &fstest.MapFile{Data: []byte("react")},
+		"web/index.js":                &fstest.MapFile{Data: []byte("old")},
+		"docs/LOCAL_NOTES.md":         &fstest.MapFile{Data: []byte("notes")},
+		"docs/old.md":                 &fstest.MapFile{Data: []byte("old")},
+		"stale/file.txt":              &fstest.MapFile{Data: []byte("old")},
+		"scratch.tmp":                 &fstest.MapFile{Data: []byte("scratch")},
+		KeepFile:                      &fstest.MapFile{Data: []byte("# local files\n*.tmp\n")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithEmptyOutputDir(true), WithKeep(".git/", ".idea/", "node_modules/", "LOCAL_NOTES.md"))
+	err := g.Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+
+	assert.Contains(t, tmpfs.MapFS, ".git/HEAD")
+	assert.Contains(t, tmpfs.MapFS, ".idea/workspace.xml")
+	assert.Contains(t, tmpfs.MapFS, "web/node_modules/react/x.js")
+	assert.Contains(t, tmpfs.MapFS, "docs/LOCAL_NOTES.md")
+	assert.Contains(t, tmpfs.MapFS, "scratch.tmp")
+	assert.Contains(t, tmpfs.MapFS, KeepFile)
+	assert.Contains(t, tmpfs.MapFS, "README.md")
+
+	assert.NotContains(t, tmpfs.MapFS, "web/index.js")
+	assert.NotContains(t, tmpfs.MapFS, "docs/old.md")
+	assert.NotContains(t, tmpfs.MapFS, "stale/file.txt")
+	assert.NotContains(t, tmpfs.MapFS, "stale")
+}
@@ -0,0 +1,197 @@
+package drydock
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownLicense = errors.New("unknown license")
+
+//go:embed licenses/*.txt
+var licenseTexts embed.FS
+
+// licenseFiles maps the SPDX ids of the bundled licenses to their texts.
+var licenseFiles = map[string]string{
+	"MIT":              "MIT.txt",
+	"Apache-2.0":       "Apache-2.0.txt",
+	"BSD-2-Clause":     "BSD-2-Clause.txt",
+	"BSD-3-Clause":     "BSD-3-Clause.txt",
+	"MPL-2.0":          "MPL-2.0.txt",
+	"GPL-3.0-only":     "GPL-3.0.txt",
+	"GPL-3.0-or-later": "GPL-3.0.txt",
+}
+
+// licenseNotices are put in front of license texts that are shared by several SPDX ids,
+// to state which version of the license is granted.
+var licenseNotices = map[string]string{
+	"GPL-3.0-only": `Copyright (C) ${year} ${holder}
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, version 3 of the License only.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+`,
+	"GPL-3.0-or-later": `Copyright (C) ${year} ${holder}
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+`,
+}
+
+// deprecatedSPDXIDs maps deprecated SPDX ids to their replacements.
+var deprecatedSPDXIDs = map[string]string{
+	"GPL-2.0":   "GPL-2.0-only",
+	"GPL-2.0+":  "GPL-2.0-or-later",
+	"GPL-3.0":   "GPL-3.0-only",
+	"GPL-3.0+":  "GPL-3.0-or-later",
+	"LGPL-2.1":  "LGPL-2.1-only",
+	"LGPL-2.1+": "LGPL-2.1-or-later",
+	"LGPL-3.0":  "LGPL-3.0-only",
+	"LGPL-3.0+": "LGPL-3.0-or-later",
+	"AGPL-3.0":  "AGPL-3.0-only",
+}
+
+// normalizeSPDXID replaces a deprecated SPDX id, e.g. `GPL-3.0` becomes `GPL-3.0-only`.
+func normalizeSPDXID(spdxID string) string {
+	if id, ok := deprecatedSPDXIDs[spdxID]; ok {
+		return id
+	}
+
+	return spdxID
+}
+
+// LicenseFile is a `LICENSE` file with the text of the license identified by spdxID. Licenses that include a
+// copyright notice, like MIT and the BSD licenses, name holder and year in it. The bundled licenses are MIT,
+// Apache-2.0, BSD-2-Clause, BSD-3-Clause, MPL-2.0, GPL-3.0-only and GPL-3.0-or-later, other ids fail with
+// [ErrUnknownLicense]. The GPL texts start with a notice stating which of the two is granted.
+// Deprecated ids are replaced, e.g. `GPL-3.0` by `GPL-3.0-only`.
+func LicenseFile(spdxID string, holder string, year int) File {
+	return &licenseFile{spdxID: normalizeSPDXID(spdxID), holder: holder, year: year}
+}
+
+type licenseFile struct {
+	spdxID string
+	holder string
+	year   int
+}
+
+func (f *licenseFile) Name() string {
+	return "LICENSE"
+}
+
+// WriteTo implements [io.WriterTo]
+func (f *licenseFile) WriteTo(w io.Writer) (int64, error) {
+	filename, ok := licenseFiles[f.spdxID]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownLicense, f.spdxID)
+	}
+
+	text, err := licenseTexts.ReadFile("licenses/" + filename)
+	if err != nil {
+		return 0, err
+	}
+
+	replacer := strings.NewReplacer("${year}", strconv.Itoa(f.year), "${holder}", f.holder)
+
+	n, err := replacer.WriteString(w, licenseNotices[f.spdxID]+string(text))
+	return int64(n), err
+}
+
+// WithLicenseHeader adds `SPDX-FileCopyrightText` and `SPDX-License-Identifier` headers to every generated source
+// file, using the comment syntax of the file extension like [GeneratedHeader]. Only files with one of the
+// [DefaultLicenseHeaderExtensions] get a header, use [WithLicenseHeaderExtensions] to change them. Files that already
+// declare a license within their first lines are left alone, as are modifications, see [ModifyFile].
+// Deprecated ids are replaced, e.g. `GPL-3.0` by `GPL-3.0-only`.
+func WithLicenseHeader(spdxID string, holder string) Option {
+	return func(g *Generator) {
+		g.licenseHeader = "SPDX-License-Identifier: " + normalizeSPDXID(spdxID)
+		if holder != "" {
+			g.licenseHeader = "SPDX-FileCopyrightText: " + holder + "\n" + g.licenseHeader
+		}
+	}
+}
+
+// DefaultLicenseHeaderExtensions are the extensions of the source files that get a header from [WithLicenseHeader].
+// Documentation, markup and configuration files, like Markdown, HTML or YAML, are not included.
+var DefaultLicenseHeaderExtensions = []string{
+	".go", ".js", ".mjs", ".cjs", ".jsx", ".ts", ".mts", ".cts", ".tsx", ".vue", ".svelte",
+	".java", ".kt", ".kts", ".scala", ".groovy", ".c", ".h", ".cpp", ".cc", ".hpp", ".cs", ".rs",
+	".swift", ".dart", ".proto", ".php", ".zig", ".sh", ".bash", ".zsh", ".fish", ".py", ".rb", ".pl",
+	".r", ".ps1", ".sql", ".lua", ".hs", ".css", ".scss", ".less",
+}
+
+// WithLicenseHeaderExtensions sets the extensions, including the leading dot, of the files that get a header from
+// [WithLicenseHeader], replacing the [DefaultLicenseHeaderExtensions]. Files without known comment syntax never get one.
+func WithLicenseHeaderExtensions(exts ...string) Option {
+	return func(g *Generator) {
+		g.licenseHeaderExts = make(map[string]bool, len(exts))
+		for _, ext := range exts {
+			g.licenseHeaderExts[strings.ToLower(ext)] = true
+		}
+	}
+}
+
+func (g *Generator) wantsLicenseHeader(file string) bool {
+	ext := strings.ToLower(path.Ext(file))
+	if g.licenseHeaderExts != nil {
+		return g.licenseHeaderExts[ext]
+	}
+
+	return slices.Contains(DefaultLicenseHeaderExtensions, ext)
+}
+
+// licenseHeaderLines is the number of lines searched for an existing SPDX license identifier.
+const licenseHeaderLines = 20
+
+func hasLicenseHeader(contents []byte) bool {
+	for i, line := range bytes.SplitAfter(contents, []byte("\n")) {
+		if i == licenseHeaderLines {
+			break
+		}
+
+		if bytes.Contains(line, []byte("SPDX-License-Identifier:")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addLicenseHeader wraps write to add the license header to the generated contents.
+func addLicenseHeader(file string, text string, write func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		var b bytes.Buffer
+		err := write(&b)
+		if err != nil {
+			return err
+		}
+
+		contents := b.Bytes()
+		if !hasLicenseHeader(contents) {
+			contents = insertHeader(file, contents, text)
+		}
+
+		_, err = w.Write(contents)
+		return err
+	}
+}
@@ -0,0 +1,98 @@
+package drydock
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicenseFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	for _, spdxID := range []string{"MIT", "BSD-2-Clause", "BSD-3-Clause", "Apache-2.0", "MPL-2.0", "GPL-3.0-only", "GPL-3.0-or-later"} {
+		t.Run(spdxID, func(t *testing.T) {
+			tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+			err := NewGenerator(tmpfs).Generate(ctx, LicenseFile(spdxID, "Jane Doe", 2026))
+			require.NoError(t, err)
+
+			exp, err := os.ReadFile("testdata/licenses/" + spdxID + ".txt")
+			require.NoError(t, err)
+
+			assert.Equal(t, string(exp), string(tmpfs.MapFS["LICENSE"].Data))
+		})
+	}
+
+	t.Run("Deprecated", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs).Generate(ctx, Dir("only", LicenseFile("GPL-3.0-only", "Jane Doe", 2026)), LicenseFile("GPL-3.0", "Jane Doe", 2026))
+		require.NoError(t, err)
+
+		license := string(tmpfs.MapFS["LICENSE"].Data)
+		assert.Equal(t, string(tmpfs.MapFS["only/LICENSE"].Data), license)
+		assert.Contains(t, license, "                    GNU GENERAL PUBLIC LICENSE\n")
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs).Generate(ctx, LicenseFile("WTFPL", "Jane Doe", 2026))
+		assert.ErrorIs(t, err, ErrUnknownLicense)
+	})
+}
+
+func TestGenerator_Generate_LicenseHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithLicenseHeader("Apache-2.0", "Jane Doe")).Generate(ctx,
+		LicenseFile("Apache-2.0", "Jane Doe", 2026),
+		PlainFile("main.go", "package main\n"),
+		PlainFile("style.css", "body {}\n"),
+		PlainFile("run.sh", "#!/bin/sh\necho\n"),
+		PlainFile("lib.go", "// SPDX-License-Identifier: MIT\n\npackage lib\n"),
+		PlainFile("data.json", "{}"),
+		PlainFile("README.md", "# drydock\n"),
+		PlainFile("config.yaml", "foo: bar\n"),
+		GeneratedHeader(PlainFile("gen.go", "package main\n")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "# drydock\n", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "foo: bar\n", string(tmpfs.MapFS["config.yaml"].Data))
+
+	assert.Equal(t, "// SPDX-FileCopyrightText: Jane Doe\n// SPDX-License-Identifier: Apache-2.0\n\npackage main\n", string(tmpfs.MapFS["main.go"].Data))
+	assert.Equal(t, "/* SPDX-FileCopyrightText: Jane Doe */\n/* SPDX-License-Identifier: Apache-2.0 */\n\nbody {}\n", string(tmpfs.MapFS["style.css"].Data))
+	assert.Equal(t, "#!/bin/sh\n# SPDX-FileCopyrightText: Jane Doe\n# SPDX-License-Identifier: Apache-2.0\n\necho\n", string(tmpfs.MapFS["run.sh"].Data))
+	assert.Equal(t, "// SPDX-License-Identifier: MIT\n\npackage lib\n", string(tmpfs.MapFS["lib.go"].Data))
+	assert.Equal(t, "{}", string(tmpfs.MapFS["data.json"].Data))
+	assert.NotContains(t, string(tmpfs.MapFS["LICENSE"].Data), "SPDX")
+
+	gen := tmpfs.MapFS["gen.go"].Data
+	assert.Equal(t, "// Code generated by drydock. DO NOT EDIT.\n\n// SPDX-FileCopyrightText: Jane Doe\n// SPDX-License-Identifier: Apache-2.0\n\npackage main\n", string(gen))
+	assert.True(t, IsGenerated("gen.go", gen))
+}
+
+func TestGenerator_Generate_LicenseHeader_Options(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithLicenseHeader("GPL-3.0", ""), WithLicenseHeaderExtensions(".md")).Generate(ctx,
+		PlainFile("main.go", "package main\n"),
+		PlainFile("README.md", "# drydock\n"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "package main\n", string(tmpfs.MapFS["main.go"].Data))
+	assert.Equal(t, "<!-- SPDX-License-Identifier: GPL-3.0-only -->\n\n# drydock\n", string(tmpfs.MapFS["README.md"].Data))
+}
@@ -0,0 +1,70 @@
+package drydock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLimitExceeded is returned when generating the files exceeds one of the [Limits]. Nothing is committed to the output.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits guard against runaway templates, e.g. a loop producing far more files than intended.
+// A limit of zero means unlimited.
+type Limits struct {
+	// MaxFiles is the maximum number of generated and modified files.
+	MaxFiles int
+	// MaxFileSize is the maximum size of a single file in bytes.
+	MaxFileSize int64
+	// MaxTotalBytes is the maximum size of all files combined in bytes.
+	MaxTotalBytes int64
+	// MaxDepth is the maximum number of path elements of a file or directory, e.g. `cmd/main.go` has a depth of 2.
+	MaxDepth int
+}
+
+// WithLimits enforces the limits while the files are written to the staging area.
+// When a limit is hit, the generation is aborted with [ErrLimitExceeded].
+func WithLimits(limits Limits) Option {
+	return func(g *Generator) {
+		g.limits = limits
+	}
+}
+
+func (g *Generator) checkDepth(p string) error {
+	if g.limits.MaxDepth <= 0 {
+		return nil
+	}
+
+	if depth := strings.Count(p, "/") + 1; depth > g.limits.MaxDepth {
+		return fmt.Errorf("%w: '%s' has a depth of %d, the maximum is %d", ErrLimitExceeded, p, depth, g.limits.MaxDepth)
+	}
+
+	return nil
+}
+
+func (r *run) checkFileCount(p string) error {
+	if r.limits.MaxFiles <= 0 {
+		return nil
+	}
+
+	if len(r.tmpfiles)+len(r.tmpmodified) >= r.limits.MaxFiles {
+		return fmt.Errorf("%w: '%s' exceeds the maximum of %d files", ErrLimitExceeded, p, r.limits.MaxFiles)
+	}
+
+	return nil
+}
+
+// checkSize returns the check for a [countingWriter] around the staging file at p.
+func (r *run) checkSize(p string) func(n int64) error {
+	return func(n int64) error {
+		if r.limits.MaxFileSize > 0 && n > r.limits.MaxFileSize {
+			return fmt.Errorf("%w: '%s' is larger than the maximum file size of %d bytes", ErrLimitExceeded, p, r.limits.MaxFileSize)
+		}
+
+		if r.limits.MaxTotalBytes > 0 && r.stagedBytes+n > r.limits.MaxTotalBytes {
+			return fmt.Errorf("%w: '%s' exceeds the maximum total size of %d bytes", ErrLimitExceeded, p, r.limits.MaxTotalBytes)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package drydock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Limits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	files := func() []File {
+		return []File{
+			PlainFile("README.md", "readme"),
+			Dir("cmd", Dir("app", PlainFile("main.go", "package main"))),
+			PlainFile("LICENSE", "license"),
+		}
+	}
+
+	tt := []struct {
+		name   string
+		limits Limits
+		err    string
+	}{
+		{name: "Unlimited", limits: Limits{}},
+		{name: "Within Limits", limits: Limits{MaxFiles: 3, MaxFileSize: 12, MaxTotalBytes: 25, MaxDepth: 3}},
+		{name: "MaxFiles", limits: Limits{MaxFiles: 2}, err: "limit exceeded: 'LICENSE' exceeds the maximum of 2 files"},
+		{name: "MaxFileSize", limits: Limits{MaxFileSize: 10}, err: "limit exceeded: 'cmd/app/main.go' is larger than the maximum file size of 10 bytes"},
+		{name: "MaxTotalBytes", limits: Limits{MaxTotalBytes: 20}, err: "limit exceeded: 'LICENSE' exceeds the maximum total size of 20 bytes"},
+		{name: "MaxDepth", limits: Limits{MaxDepth: 1}, err: "limit exceeded: 'cmd/app' has a depth of 2, the maximum is 1"},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+			err := NewGenerator(tmpfs, WithLimits(tt.limits)).Generate(ctx, files()...)
+			if tt.err == "" {
+				require.NoError(t, err)
+				assert.Contains(t, tmpfs.MapFS, "cmd/app/main.go")
+				return
+			}
+
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			assert.EqualError(t, err, tt.err)
+			assert.NotContains(t, tmpfs.MapFS, "README.md")
+		})
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"README.md": &fstest.MapFile{Data: []byte("readme")},
+		}, baseDir: "."}
+
+		merge := func(existing []byte, generated []byte, w io.Writer) error {
+			for range 10 {
+				_, err := w.Write(append(existing, generated...))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		err := NewGenerator(tmpfs, WithLimits(Limits{MaxFileSize: 50})).Generate(ctx,
+			OnConflictMerge(PlainFile("README.md", "new readme"), merge),
+		)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Equal(t, "readme", string(tmpfs.MapFS["README.md"].Data))
+
+		err = NewGenerator(tmpfs, WithLimits(Limits{MaxTotalBytes: 20})).Generate(ctx,
+			PlainFile("LICENSE", "license"),
+			OnConflictMerge(PlainFile("README.md", "readme"), merge),
+		)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		assert.NotContains(t, tmpfs.MapFS, "LICENSE")
+	})
+
+	t.Run("Runaway Loop", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		files := make([]File, 0, 1000)
+		for i := range 1000 {
+			files = append(files, PlainFile(fmt.Sprintf("file_%d", i), "contents"))
+		}
+
+		err := NewGenerator(tmpfs, WithLimits(Limits{MaxFiles: 100})).Generate(ctx, files...)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Empty(t, tmpfs.MapFS)
+	})
+}
@@ -0,0 +1,243 @@
+package drydock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockPath is the location of the lock file, relative to the output root, used by [WithLock].
+const LockPath = ".drydock/lock"
+
+// DefaultLockTimeout is the time [WithLock] waits for a lock by default, see [WithLockTimeout].
+const DefaultLockTimeout = 30 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// ErrLocked is returned when the output is locked by another generator and the lock could not be acquired in time.
+var ErrLocked = errors.New("output is locked")
+
+// LockFS is an [OutputFS] that can be locked exclusively, see [WithLock].
+type LockFS interface {
+	OutputFS
+
+	// Lock blocks until an exclusive lock on the output is acquired or ctx is done.
+	// The returned function releases the lock.
+	Lock(ctx context.Context) (unlock func() error, err error)
+}
+
+// WithLock takes an exclusive lock on the output for the duration of [Generator.Generate], or from
+// [Generator.Stage] until [Staged.Commit] or [Staged.Abort], so concurrent generators writing to the same
+// output don't interleave.
+// The output must implement [LockFS].
+func WithLock(b bool) Option {
+	return func(g *Generator) {
+		g.lock = b
+	}
+}
+
+// WithLockTimeout sets how long [WithLock] waits for the lock before returning [ErrLocked].
+func WithLockTimeout(d time.Duration) Option {
+	return func(g *Generator) {
+		g.lockTimeout = d
+	}
+}
+
+func (g *Generator) acquireLock(ctx context.Context) (func() error, error) {
+	if !g.lock {
+		return func() error { return nil }, nil
+	}
+
+	lockFS, ok := g.output.(LockFS)
+	if !ok {
+		return nil, fmt.Errorf("output %T does not support locking", g.output)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.lockTimeout)
+	defer cancel()
+
+	return lockFS.Lock(ctx)
+}
+
+// lockOwner is the content of the lock file.
+type lockOwner struct {
+	pid      int
+	hostname string
+}
+
+func (o lockOwner) String() string {
+	return fmt.Sprintf("%d\n%s\n", o.pid, o.hostname)
+}
+
+func parseLockOwner(data []byte) (lockOwner, bool) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		return lockOwner{}, false
+	}
+
+	pid, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return lockOwner{}, false
+	}
+
+	return lockOwner{pid: pid, hostname: lines[1]}, true
+}
+
+func currentLockOwner() lockOwner {
+	hostname, _ := os.Hostname()
+	return lockOwner{pid: os.Getpid(), hostname: hostname}
+}
+
+// Lock implements [LockFS] using a lock file at [LockPath] containing the PID and hostname of the owner.
+// On Linux the file is additionally locked using flock(2). Locks of processes on the same host that are no
+// longer running are considered stale and removed, after checking again that the lock file has not been
+// replaced by a new owner in the meantime. The `.drydock` dir is removed on unlock if it is left empty.
+func (ofs *osOutputFS) Lock(ctx context.Context) (func() error, error) {
+	lockpath := path.Join(ofs.baseDir, LockPath)
+
+	for {
+		unlock, err := tryLockFile(lockpath)
+		if err == nil {
+			return unlock, nil
+		}
+
+		if errors.Is(err, os.ErrNotExist) {
+			// the dir doesn't exist yet or was removed by the previous owner on unlock
+			err = os.MkdirAll(path.Dir(lockpath), 0o755)
+			if err != nil {
+				return nil, fmt.Errorf("error creating lock file: %w", err)
+			}
+
+			continue
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error creating lock file: %w", err)
+		}
+
+		owner, stale := isStaleLockFile(lockpath)
+		if stale {
+			err = removeStaleLockFile(lockpath)
+			if err != nil {
+				return nil, fmt.Errorf("error removing stale lock file: %w", err)
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: held by pid %d on %s: %w", ErrLocked, owner.pid, owner.hostname, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func tryLockFile(lockpath string) (func() error, error) {
+	f, err := os.OpenFile(lockpath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = flock(f)
+	if err != nil {
+		return nil, errors.Join(err, f.Close(), os.Remove(lockpath))
+	}
+
+	_, err = f.WriteString(currentLockOwner().String())
+	if err != nil {
+		return nil, errors.Join(err, f.Close(), os.Remove(lockpath))
+	}
+
+	return func() error {
+		return errors.Join(os.Remove(lockpath), f.Close(), removeEmptyLockDir(path.Dir(lockpath)))
+	}, nil
+}
+
+// removeEmptyLockDir removes the dir of the lock file, unless something else was written to it.
+func removeEmptyLockDir(dir string) error {
+	err := os.Remove(dir)
+	if err != nil && !errors.Is(err, os.ErrExist) && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing lock dir: %w", err)
+	}
+
+	return nil
+}
+
+// isStaleLockFile reports whether the owner of the lock is no longer running.
+func isStaleLockFile(lockpath string) (lockOwner, bool) {
+	data, err := os.ReadFile(lockpath)
+	if err != nil {
+		return lockOwner{}, errors.Is(err, os.ErrNotExist)
+	}
+
+	owner, ok := parseLockOwner(data)
+	if !ok {
+		// the owner might not have written its PID yet
+		return owner, false
+	}
+
+	if owner.dead() {
+		return owner, true
+	}
+
+	return owner, !flockHeld(lockpath)
+}
+
+// dead reports whether the owner is a process on this host that is no longer running.
+func (o lockOwner) dead() bool {
+	return o.hostname == currentLockOwner().hostname && !processAlive(o.pid)
+}
+
+// mapfsLock is the lock of a [fstest.MapFS], refs counts the holders and waiters so it can be removed once unused.
+type mapfsLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+var (
+	mapfsLocksMu sync.Mutex
+	mapfsLocks   = map[uintptr]*mapfsLock{}
+)
+
+// Lock implements [LockFS] using an in-process lock shared by all [MapFSOutputFS] using the same [fstest.MapFS].
+func (fsys *MapFSOutputFS) Lock(ctx context.Context) (func() error, error) {
+	key := reflect.ValueOf(fsys.MapFS).Pointer()
+
+	mapfsLocksMu.Lock()
+	lock, ok := mapfsLocks[key]
+	if !ok {
+		lock = &mapfsLock{sem: make(chan struct{}, 1)}
+		mapfsLocks[key] = lock
+	}
+	lock.refs++
+	mapfsLocksMu.Unlock()
+
+	release := func() {
+		mapfsLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(mapfsLocks, key)
+		}
+		mapfsLocksMu.Unlock()
+	}
+
+	select {
+	case lock.sem <- struct{}{}:
+		return func() error {
+			<-lock.sem
+			release()
+			return nil
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, fmt.Errorf("%w: %w", ErrLocked, ctx.Err())
+	}
+}
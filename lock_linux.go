@@ -0,0 +1,94 @@
+package drydock
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// flock blocks until an exclusive flock(2) is held on the file. Another process only holds it briefly
+// while checking whether a new lock file is stale, see [removeStaleLockFile].
+func flock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// flockHeld reports whether another process holds a flock(2) on the file.
+func flockHeld(lockpath string) bool {
+	f, err := os.Open(lockpath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK)
+	}
+
+	return false
+}
+
+// removeStaleLockFile removes the lock file while holding its flock(2), after checking again that it is still
+// the same file and that its owner has written its PID and no longer holds the lock or is no longer running.
+// Owners hold the flock for as long as they hold the lock, so a lock taken in the meantime is never removed.
+func removeStaleLockFile(lockpath string) error {
+	f, err := os.Open(lockpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil
+		}
+
+		return err
+	}
+
+	opened, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	current, err := os.Stat(lockpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !os.SameFile(opened, current) {
+		return nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	owner, ok := parseLockOwner(data)
+	if !ok || (owner.hostname == currentLockOwner().hostname && processAlive(owner.pid)) {
+		return nil
+	}
+
+	err = os.Remove(lockpath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
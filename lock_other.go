@@ -0,0 +1,90 @@
+//go:build !linux
+
+package drydock
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+func flock(_ *os.File) error {
+	return nil
+}
+
+// flockHeld always reports true, as without flock(2) a lock can only be detected as stale by its PID.
+func flockHeld(_ string) bool {
+	return true
+}
+
+// removeStaleLockFile moves the lock file aside before removing it. If it turns out to be a different file
+// than the one found to be stale, i.e. a new owner replaced it in the meantime, it is moved back.
+func removeStaleLockFile(lockpath string) error {
+	stale, err := os.Stat(lockpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	data, err := os.ReadFile(lockpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	owner, ok := parseLockOwner(data)
+	if !ok || !owner.dead() {
+		return nil
+	}
+
+	aside := lockpath + "." + strconv.Itoa(os.Getpid()) + ".stale"
+
+	err = os.Rename(lockpath, aside)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	moved, err := os.Stat(aside)
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(stale, moved) {
+		// a link fails instead of replacing a lock file created after the rename
+		err = os.Link(aside, lockpath)
+		if err != nil && !errors.Is(err, os.ErrExist) {
+			return errors.Join(err, os.Remove(aside))
+		}
+	}
+
+	return os.Remove(aside)
+}
+
+// processAlive reports whether a process with the PID exists. On Windows finding the process is enough,
+// elsewhere it is sent signal 0.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release() //nolint: errcheck
+
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
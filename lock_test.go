@@ -0,0 +1,152 @@
+package drydock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Lock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("MapFSOutputFS Concurrent", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		var mu sync.Mutex
+		active := 0
+		maxActive := 0
+		observer := ObserverFunc(func(_ context.Context, event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch event.Type {
+			case EventFileRendered:
+				active++
+				maxActive = max(maxActive, active)
+			case EventFileCommitted:
+				active--
+			default:
+			}
+		})
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g := NewGenerator(tmpfs, WithLock(true), WithObserver(observer))
+				errs[i] = g.Generate(ctx, PlainFile(fmt.Sprintf("file_%d", i), "contents"))
+			}()
+		}
+
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, 1, maxActive)
+	})
+
+	t.Run("MapFSOutputFS Timeout", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		unlock, err := tmpfs.Lock(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = unlock() })
+
+		g := NewGenerator(tmpfs, WithLock(true), WithLockTimeout(10*time.Millisecond))
+		err = g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.ErrorIs(t, err, ErrLocked)
+		assert.NotContains(t, tmpfs.MapFS, "README.md")
+	})
+
+	t.Run("OSOutputFS", func(t *testing.T) {
+		outpath := t.TempDir()
+		outfs := NewOSOutputFS(outpath)
+
+		unlock, err := outfs.(LockFS).Lock(ctx)
+		require.NoError(t, err)
+
+		lockfile, err := os.ReadFile(filepath.Join(outpath, LockPath))
+		require.NoError(t, err)
+		assert.Equal(t, currentLockOwner().String(), string(lockfile))
+
+		g := NewGenerator(outfs, WithLock(true), WithLockTimeout(10*time.Millisecond))
+		err = g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.ErrorIs(t, err, ErrLocked)
+
+		require.NoError(t, unlock())
+		assert.NoFileExists(t, filepath.Join(outpath, LockPath))
+		assert.NoDirExists(t, filepath.Join(outpath, ".drydock"))
+
+		g = NewGenerator(outfs, WithLock(true), WithLockTimeout(10*time.Millisecond))
+		err = g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(outpath, LockPath))
+		assert.NoDirExists(t, filepath.Join(outpath, ".drydock"))
+
+		require.NoError(t, NewGenerator(outfs, WithLock(true), WithPrune(true), WithErrorOnExistingFile(false)).Generate(ctx, PlainFile("README.md", "# drydock")))
+		assert.FileExists(t, filepath.Join(outpath, ManifestPath))
+	})
+
+	t.Run("OSOutputFS Stale", func(t *testing.T) {
+		if !processAlive(os.Getpid()) || processAlive(1<<22+1) {
+			t.Skip("stale lock detection is not supported on this platform")
+		}
+
+		outpath := t.TempDir()
+		outfs := NewOSOutputFS(outpath)
+
+		stale := lockOwner{pid: 1<<22 + 1, hostname: currentLockOwner().hostname}
+		require.NoError(t, os.MkdirAll(filepath.Join(outpath, ".drydock"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(outpath, LockPath), []byte(stale.String()), 0o644))
+
+		g := NewGenerator(outfs, WithLock(true), WithLockTimeout(time.Second))
+		err := g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.NoError(t, err)
+	})
+
+	t.Run("OSOutputFS Stale Replaced", func(t *testing.T) {
+		outpath := t.TempDir()
+		outfs := NewOSOutputFS(outpath)
+
+		unlock, err := outfs.(LockFS).Lock(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = unlock() })
+
+		// the lock was found to be stale, but has been replaced by a live owner before it is removed
+		require.NoError(t, removeStaleLockFile(filepath.Join(outpath, LockPath)))
+		assert.FileExists(t, filepath.Join(outpath, LockPath))
+	})
+
+	t.Run("MapFSOutputFS Unlock", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		unlock, err := tmpfs.Lock(ctx)
+		require.NoError(t, err)
+
+		timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancelTimeout()
+
+		_, err = tmpfs.Lock(timeoutCtx)
+		require.ErrorIs(t, err, ErrLocked)
+
+		require.NoError(t, unlock())
+
+		mapfsLocksMu.Lock()
+		defer mapfsLocksMu.Unlock()
+		assert.NotContains(t, mapfsLocks, reflect.ValueOf(tmpfs.MapFS).Pointer())
+	})
+}
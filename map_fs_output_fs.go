@@ -19,14 +19,128 @@ type MapFSOutputFS struct {
 	baseDir string
 }
 
-var _ OutputFS = (*MapFSOutputFS)(nil)
+var (
+	_ ChtimesFS = (*MapFSOutputFS)(nil)
+	_ ChmodFS   = (*MapFSOutputFS)(nil)
+	_ SyncFS    = (*MapFSOutputFS)(nil)
+	_ TempDirFS = (*MapFSOutputFS)(nil)
+)
+
+func (fsys *MapFSOutputFS) rooted() bool {
+	return fsys.baseDir == "" || fsys.baseDir == "."
+}
+
+// Open opens name relative to the base dir. Files are looked up directly, directories are assembled from the
+// entries below them, without copying the rest of the map.
+func (fsys *MapFSOutputFS) Open(name string) (fs.File, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.Open(name)
+	}
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	full := path.Join(fsys.baseDir, name)
+
+	file, ok := fsys.MapFS[full]
+	if ok && !file.Mode.IsDir() {
+		return fstest.MapFS{name: file}.Open(name)
+	}
+
+	dir := fstest.MapFS{}
+	if ok {
+		dir[name] = file
+	}
+
+	prefix := full + "/"
+	for p, f := range fsys.MapFS {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+
+		child, rest, nested := strings.Cut(p[len(prefix):], "/")
+		if nested && rest != "" {
+			if _, exists := dir[path.Join(name, child)]; !exists {
+				dir[path.Join(name, child)] = &fstest.MapFile{Mode: fs.ModeDir | 0o555}
+			}
+
+			continue
+		}
+
+		dir[path.Join(name, child)] = f
+	}
+
+	return dir.Open(name)
+}
+
+// openOnlyFS hides the other methods of an [fs.FS], so the fs package helpers fall back to Open.
+type openOnlyFS struct {
+	fs.FS
+}
+
+func (fsys *MapFSOutputFS) ReadFile(name string) ([]byte, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.ReadFile(name)
+	}
+
+	return fs.ReadFile(openOnlyFS{fsys}, name)
+}
+
+func (fsys *MapFSOutputFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.ReadDir(name)
+	}
+
+	return fs.ReadDir(openOnlyFS{fsys}, name)
+}
+
+func (fsys *MapFSOutputFS) Stat(name string) (fs.FileInfo, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.Stat(name)
+	}
+
+	return fs.Stat(openOnlyFS{fsys}, name)
+}
+
+func (fsys *MapFSOutputFS) Glob(pattern string) ([]string, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.Glob(pattern)
+	}
+
+	return fs.Glob(openOnlyFS{fsys}, pattern)
+}
+
+func (fsys *MapFSOutputFS) Sub(dir string) (fs.FS, error) {
+	if fsys.rooted() {
+		return fsys.MapFS.Sub(dir)
+	}
+
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+
+	return &MapFSOutputFS{MapFS: fsys.MapFS, baseDir: path.Join(fsys.baseDir, dir)}, nil
+}
 
 func (fsys *MapFSOutputFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
 	name = path.Join(fsys.baseDir, name)
-	file := &fstest.MapFile{Mode: perm}
+
+	existing, exists := fsys.MapFS[name]
+	if exists && flag&os.O_EXCL != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+
+	file := &fstest.MapFile{Mode: perm, ModTime: time.Now()}
 	fsys.MapFS[name] = file
 
-	return &writableMapFSFile{MapFile: file, name: name}, nil
+	f := &writableMapFSFile{MapFile: file, name: name}
+	if exists && flag&os.O_APPEND != 0 {
+		f.b.Write(existing.Data)
+		file.Data = f.b.Bytes()
+	}
+
+	return f, nil
 }
 
 func (fsys *MapFSOutputFS) Mkdir(name string) error {
@@ -36,12 +150,51 @@ func (fsys *MapFSOutputFS) Mkdir(name string) error {
 		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
 	}
 
-	fsys.MapFS[name] = &fstest.MapFile{Mode: 0o755 | os.ModeDir}
+	fsys.MapFS[name] = &fstest.MapFile{Mode: 0o755 | os.ModeDir, ModTime: time.Now()}
+
+	return nil
+}
+
+func (fsys *MapFSOutputFS) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	name = path.Join(fsys.baseDir, name)
+
+	file, exists := fsys.MapFS[name]
+	if !exists {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file.ModTime = mtime
+
+	return nil
+}
+
+func (fsys *MapFSOutputFS) Chmod(name string, mode fs.FileMode) error {
+	name = path.Join(fsys.baseDir, name)
+
+	file, exists := fsys.MapFS[name]
+	if !exists {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file.Mode = file.Mode.Type() | mode.Perm()
 
 	return nil
 }
 
+// Sync implements [SyncFS]. The MapFS is kept in memory, so there is nothing to sync.
+func (fsys *MapFSOutputFS) Sync(string) error {
+	return nil
+}
+
 func (fsys *MapFSOutputFS) Rename(oldpath string, newpath string) error {
+	if !path.IsAbs(oldpath) {
+		oldpath = path.Join(fsys.baseDir, oldpath)
+	}
+
+	if !path.IsAbs(newpath) {
+		newpath = path.Join(fsys.baseDir, newpath)
+	}
+
 	if oldpath == newpath {
 		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EEXIST}
 	}
@@ -54,6 +207,15 @@ func (fsys *MapFSOutputFS) Rename(oldpath string, newpath string) error {
 	delete(fsys.MapFS, oldpath)
 	fsys.MapFS[newpath] = file
 
+	if file.Mode.IsDir() {
+		for p, f := range fsys.MapFS {
+			if strings.HasPrefix(p, oldpath+"/") {
+				delete(fsys.MapFS, p)
+				fsys.MapFS[newpath+p[len(oldpath):]] = f
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -66,7 +228,11 @@ func (fsys *MapFSOutputFS) Remove(name string) error {
 	}
 
 	if file.Mode&os.ModeDir != 0 {
-		panic("AAAHH")
+		for p := range fsys.MapFS {
+			if path.Dir(p) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+			}
+		}
 	}
 
 	delete(fsys.MapFS, name)
@@ -79,13 +245,9 @@ func (fsys *MapFSOutputFS) RemoveAll(name string) error {
 		name = path.Join(fsys.baseDir, name)
 	}
 
-	if _, exists := fsys.MapFS[name]; !exists && name != "." {
-		return nil
-	}
-
 	toRemove := []string{}
 	for p := range fsys.MapFS {
-		if p == name || path.Dir(p) == name {
+		if p == name || (name == "." && !path.IsAbs(p)) || strings.HasPrefix(p, name+"/") {
 			toRemove = append(toRemove, p)
 		}
 	}
@@ -112,6 +274,25 @@ func (fsys *MapFSOutputFS) MkdirTemp(pattern string) (OutputFS, string, error) {
 	return &MapFSOutputFS{MapFS: fsys.MapFS, baseDir: dirpath}, dirpath, nil
 }
 
+func (fsys *MapFSOutputFS) TempDirs(pattern string) ([]string, error) {
+	var dirs []string
+	for p, f := range fsys.MapFS {
+		if path.Dir(p) != "/tmp" || !f.Mode.IsDir() {
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			dirs = append(dirs, p)
+		}
+	}
+
+	return dirs, nil
+}
+
+func (fsys *MapFSOutputFS) OpenTempDir(dir string) (OutputFS, error) {
+	return &MapFSOutputFS{MapFS: fsys.MapFS, baseDir: dir}, nil
+}
+
 type writableMapFSFile struct {
 	*fstest.MapFile
 	name string
@@ -123,7 +304,9 @@ func (f *writableMapFSFile) Name() string {
 }
 
 func (f *writableMapFSFile) Write(p []byte) (int, error) {
-	return f.b.Write(p)
+	n, err := f.b.Write(p)
+	f.Data = f.b.Bytes()
+	return n, err
 }
 
 func (f *writableMapFSFile) Read(p []byte) (int, error) {
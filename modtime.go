@@ -0,0 +1,71 @@
+package drydock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WithModTime sets the modification time of every created or modified file and directory to t,
+// for reproducible output. The output must implement [ChtimesFS], otherwise the times are left unchanged.
+// By default the time from the `SOURCE_DATE_EPOCH` environment variable is used, if it is set.
+// Passing the zero [time.Time] disables setting the modification time.
+func WithModTime(t time.Time) Option {
+	return func(g *Generator) {
+		g.modTime = t
+	}
+}
+
+// sourceDateEpoch returns the time set by the `SOURCE_DATE_EPOCH` environment variable
+// (see https://reproducible-builds.org/specs/source-date-epoch/) or the zero time.
+func sourceDateEpoch() time.Time {
+	epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok {
+		return time.Time{}
+	}
+
+	secs, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(secs, 0).UTC()
+}
+
+// setModTimes sets the modification time of all written files and staged directories. Unchanged files are
+// replaced by their staged copy as well, so they get the modification time too.
+func (r *run) setModTimes() error {
+	if r.modTime.IsZero() {
+		return nil
+	}
+
+	chtimesFS, ok := r.output.(ChtimesFS)
+	if !ok {
+		return nil
+	}
+
+	paths := make([]string, 0, len(r.result.Files)+len(r.tmpdirs))
+	for _, f := range r.result.Files {
+		switch f.Outcome {
+		case OutcomeCreated, OutcomeOverwritten, OutcomeModified, OutcomeUnchanged:
+			paths = append(paths, f.Path)
+		case OutcomeSkipped, OutcomeDeleted:
+		}
+	}
+
+	for dir := range r.tmpdirs {
+		paths = append(paths, dir)
+	}
+
+	for _, p := range paths {
+		err := chtimesFS.Chtimes(p, r.modTime, r.modTime)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error setting modification time of '%s': %w", p, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_ModTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("MapFSOutputFS", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"config.ini": &fstest.MapFile{Data: []byte("foo = bar")},
+		}, baseDir: "."}
+
+		g := NewGenerator(tmpfs, WithModTime(modTime))
+		err := g.Generate(ctx,
+			Dir("cmd", PlainFile("main.go", "package main")),
+			ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+				_, err := w.Write(append(contents, []byte("\nbaz = bat")...))
+				return err
+			}),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, modTime, tmpfs.MapFS["cmd"].ModTime)
+		assert.Equal(t, modTime, tmpfs.MapFS["cmd/main.go"].ModTime)
+		assert.Equal(t, modTime, tmpfs.MapFS["config.ini"].ModTime)
+	})
+
+	t.Run("OSOutputFS", func(t *testing.T) {
+		outpath := t.TempDir()
+
+		g := NewGenerator(NewOSOutputFS(outpath), WithModTime(modTime))
+		err := g.Generate(ctx, Dir("cmd", PlainFile("main.go", "package main")))
+		require.NoError(t, err)
+
+		for _, p := range []string{"cmd", filepath.Join("cmd", "main.go")} {
+			stat, err := os.Stat(filepath.Join(outpath, p))
+			require.NoError(t, err)
+			assert.True(t, modTime.Equal(stat.ModTime()), p)
+		}
+	})
+
+	t.Run("Regenerate", func(t *testing.T) {
+		outpath := t.TempDir()
+
+		for range 2 {
+			g := NewGenerator(NewOSOutputFS(outpath), WithModTime(modTime), WithErrorOnExistingFile(false))
+			result, err := g.GenerateWithResult(ctx, PlainFile("README.md", "# drydock"))
+			require.NoError(t, err)
+
+			stat, err := os.Stat(filepath.Join(outpath, "README.md"))
+			require.NoError(t, err)
+			assert.True(t, modTime.Equal(stat.ModTime()), result.Files)
+		}
+	})
+
+	t.Run("SOURCE_DATE_EPOCH", func(t *testing.T) {
+		t.Setenv("SOURCE_DATE_EPOCH", "1704164645")
+
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		g := NewGenerator(tmpfs)
+		err := g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.NoError(t, err)
+
+		assert.Equal(t, modTime, tmpfs.MapFS["README.md"].ModTime)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		before := time.Now()
+		g := NewGenerator(tmpfs, WithModTime(time.Time{}))
+		err := g.Generate(ctx, PlainFile("README.md", "# drydock"))
+		require.NoError(t, err)
+
+		assert.False(t, tmpfs.MapFS["README.md"].ModTime.Before(before))
+	})
+}
@@ -0,0 +1,111 @@
+package drydock
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// EventType identifies the operation an [Event] reports.
+type EventType string
+
+const (
+	// EventDirStaged is emitted when a directory was created in the staging area.
+	EventDirStaged EventType = "dir_staged"
+	// EventFileRendered is emitted when a file was written to the staging area.
+	EventFileRendered EventType = "file_rendered"
+	// EventFileModified is emitted when a modification of an existing file was written to the staging area.
+	EventFileModified EventType = "file_modified"
+	// EventFileCommitted is emitted when a file was moved from the staging area into the output.
+	EventFileCommitted EventType = "file_committed"
+	// EventFileSkipped is emitted when a staged file was not moved into the output, because it was skipped.
+	EventFileSkipped EventType = "file_skipped"
+	// EventFileDeleted is emitted when a file was removed from the output by pruning.
+	EventFileDeleted EventType = "file_deleted"
+	// EventCleanStarted is emitted before the output dir is emptied, see [WithEmptyOutputDir].
+	EventCleanStarted EventType = "clean_started"
+	// EventCleanFinished is emitted after the output dir was emptied.
+	EventCleanFinished EventType = "clean_finished"
+	// EventRollback is emitted when the generation failed and the staging area is discarded.
+	EventRollback EventType = "rollback"
+)
+
+// Event describes a single operation of the [Generator].
+type Event struct {
+	Type EventType
+	Path string
+	// Size of the file in bytes, if applicable.
+	Size int64
+	// Duration of the operation, if applicable.
+	Duration time.Duration
+	// Outcome of the file for [EventFileCommitted], [EventFileSkipped] and [EventFileDeleted].
+	Outcome FileOutcome
+	// Err is the error causing an [EventRollback].
+	Err error
+}
+
+// Observer is notified of every operation of the [Generator], see [WithObserver].
+// Observe is called synchronously and should return quickly.
+type Observer interface {
+	Observe(ctx context.Context, event Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as [Observer].
+type ObserverFunc func(ctx context.Context, event Event)
+
+// Observe implements [Observer].
+func (f ObserverFunc) Observe(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// WithObserver adds an [Observer] that is notified of every operation, e.g. to display progress or for logging.
+func WithObserver(o Observer) Option {
+	return func(g *Generator) {
+		g.observers = append(g.observers, o)
+	}
+}
+
+// NewSlogObserver returns an [Observer] that logs every event to logger.
+// Rollbacks are logged at [slog.LevelError], all other events at [slog.LevelDebug].
+func NewSlogObserver(logger *slog.Logger) Observer {
+	return &slogObserver{logger: logger}
+}
+
+type slogObserver struct {
+	logger *slog.Logger
+}
+
+func (o *slogObserver) Observe(ctx context.Context, event Event) {
+	level := slog.LevelDebug
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs, slog.String("event", string(event.Type)))
+
+	if event.Path != "" {
+		attrs = append(attrs, slog.String("path", event.Path))
+	}
+
+	if event.Size != 0 {
+		attrs = append(attrs, slog.Int64("size", event.Size))
+	}
+
+	if event.Duration != 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+
+	if event.Outcome != "" {
+		attrs = append(attrs, slog.String("outcome", string(event.Outcome)))
+	}
+
+	if event.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", event.Err))
+	}
+
+	o.logger.LogAttrs(ctx, level, "drydock: "+string(event.Type), attrs...)
+}
+
+func (g *Generator) emit(ctx context.Context, event Event) {
+	for _, o := range g.observers {
+		o.Observe(ctx, event)
+	}
+}
@@ -0,0 +1,76 @@
+package drydock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Observer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"LICENSE": &fstest.MapFile{Data: []byte("license")},
+	}, baseDir: "."}
+
+	var events []Event
+	observer := ObserverFunc(func(_ context.Context, event Event) {
+		event.Duration = 0
+		events = append(events, event)
+	})
+
+	g := NewGenerator(tmpfs, WithObserver(observer))
+	err := g.Generate(ctx,
+		Dir("cmd", PlainFile("main.go", "package main")),
+		OnConflict(PlainFile("LICENSE", "new license"), ConflictSkip),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Event{
+		{Type: EventDirStaged, Path: "cmd"},
+		{Type: EventFileRendered, Path: "cmd/main.go", Size: 12},
+		{Type: EventFileRendered, Path: "LICENSE", Size: 11},
+		{Type: EventFileCommitted, Path: "cmd/main.go", Size: 12, Outcome: OutcomeCreated},
+		{Type: EventFileSkipped, Path: "LICENSE", Outcome: OutcomeSkipped},
+	}, events)
+
+	events = nil
+
+	g = NewGenerator(tmpfs, WithObserver(observer))
+	err = g.Generate(ctx, PlainFile("LICENSE", "new license"))
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, EventFileRendered, events[0].Type)
+	assert.Equal(t, EventRollback, events[1].Type)
+	assert.ErrorIs(t, events[1].Err, fs.ErrExist)
+}
+
+func TestSlogObserver(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	o := NewSlogObserver(logger)
+	o.Observe(context.Background(), Event{Type: EventFileCommitted, Path: "main.go", Size: 12, Outcome: OutcomeCreated})
+	o.Observe(context.Background(), Event{Type: EventRollback, Err: errors.New("test error")})
+
+	assert.Equal(t, `level=DEBUG msg="drydock: file_committed" event=file_committed path=main.go size=12 outcome=created
+level=ERROR msg="drydock: rollback" event=rollback error="test error"
+`, b.String())
+}
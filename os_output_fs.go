@@ -1,12 +1,17 @@
 package drydock
 
 import (
+	"errors"
 	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// osOutputFS is an [OutputFS] rooted at baseDir. Absolute paths, like the temporary directories created by
+// [osOutputFS.MkdirTemp], are not relative to the base dir.
 type osOutputFS struct {
 	fs.ReadFileFS
 	baseDir string
@@ -28,6 +33,10 @@ func (ofs *osOutputFS) Mkdir(name string) error {
 }
 
 func (ofs *osOutputFS) Rename(oldpath string, newpath string) error {
+	if !path.IsAbs(oldpath) {
+		oldpath = path.Join(ofs.baseDir, oldpath)
+	}
+
 	if !strings.HasPrefix(newpath, ofs.baseDir) {
 		newpath = path.Join(ofs.baseDir, newpath)
 	}
@@ -36,11 +45,45 @@ func (ofs *osOutputFS) Rename(oldpath string, newpath string) error {
 }
 
 func (ofs *osOutputFS) Remove(p string) error {
-	return os.Remove(path.Join(ofs.baseDir, p))
+	if !path.IsAbs(p) {
+		p = path.Join(ofs.baseDir, p)
+	}
+
+	return os.Remove(p)
 }
 
 func (ofs *osOutputFS) RemoveAll(p string) error {
-	return os.RemoveAll(path.Join(ofs.baseDir, p))
+	if !path.IsAbs(p) {
+		p = path.Join(ofs.baseDir, p)
+	}
+
+	return os.RemoveAll(p)
+}
+
+func (ofs *osOutputFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(path.Join(ofs.baseDir, name), atime, mtime)
+}
+
+func (ofs *osOutputFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(path.Join(ofs.baseDir, name), mode)
+}
+
+func (ofs *osOutputFS) Chown(name string, uid int, gid int) error {
+	return os.Chown(path.Join(ofs.baseDir, name), uid, gid)
+}
+
+// Sync implements [SyncFS].
+func (ofs *osOutputFS) Sync(name string) error {
+	if !path.IsAbs(name) {
+		name = path.Join(ofs.baseDir, name)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(f.Sync(), f.Close())
 }
 
 func (ofs *osOutputFS) ReadDir(name string) ([]fs.DirEntry, error) {
@@ -55,3 +98,11 @@ func (ofs *osOutputFS) MkdirTemp(pattern string) (OutputFS, string, error) {
 
 	return NewOSOutputFS(dir), dir, nil
 }
+
+func (ofs *osOutputFS) TempDirs(pattern string) ([]string, error) {
+	return filepath.Glob(filepath.Join(os.TempDir(), pattern))
+}
+
+func (ofs *osOutputFS) OpenTempDir(dir string) (OutputFS, error) {
+	return NewOSOutputFS(dir), nil
+}
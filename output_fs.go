@@ -2,6 +2,7 @@ package drydock
 
 import (
 	"io/fs"
+	"time"
 )
 
 // OutputFS extends the standard [io/fs.FS] interface with writing capabilities for
@@ -27,3 +28,39 @@ type OutputFS interface {
 	// The caller is responsible for removing and temporary directories, they will not be cleaned up automatically.
 	MkdirTemp(pattern string) (OutputFS, string, error)
 }
+
+// ChtimesFS is an [OutputFS] that can change the access and modification times of files and directories,
+// like [os.Chtimes]. It is used by [WithModTime].
+type ChtimesFS interface {
+	OutputFS
+
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// TempDirFS is an [OutputFS] that can find the temporary directories created by [OutputFS.MkdirTemp],
+// e.g. to remove directories orphaned by a crash.
+type TempDirFS interface {
+	OutputFS
+
+	// TempDirs returns the paths of all temporary directories matching the pattern passed to [OutputFS.MkdirTemp].
+	TempDirs(pattern string) ([]string, error)
+
+	// OpenTempDir returns an [OutputFS] rooted at a temporary directory returned by [TempDirFS.TempDirs].
+	OpenTempDir(dir string) (OutputFS, error)
+}
+
+// ChmodFS is an [OutputFS] that can change the mode of files, like [os.Chmod].
+// It is used to keep the mode of modified files, see [ModifyFile].
+type ChmodFS interface {
+	OutputFS
+
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// ChownFS is an [OutputFS] that can change the owner of files, like [os.Chown].
+// It is used to keep the owner of modified files, see [ModifyFile].
+type ChownFS interface {
+	OutputFS
+
+	Chown(name string, uid int, gid int) error
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package drydock
+
+import (
+	"io/fs"
+)
+
+// fileOwner never reports an owner, so ownership is only kept on Linux.
+func fileOwner(_ fs.FileInfo) (uid int, gid int, ok bool) {
+	return 0, 0, false
+}
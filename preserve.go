@@ -0,0 +1,105 @@
+package drydock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// WithPreserveLineEndings keeps the line endings and the UTF-8 byte order mark of modified files, see [ModifyFile].
+// The modifier receives the contents without the byte order mark and with `\n` line endings. Unless its output
+// contains `\r\n` line endings or a byte order mark itself, the original ones are restored.
+func WithPreserveLineEndings(b bool) Option {
+	return func(g *Generator) {
+		g.preserveLineEndings = b
+	}
+}
+
+// textStyle is the line ending style and byte order mark of a file.
+type textStyle struct {
+	crlf bool
+	bom  bool
+}
+
+// detectTextStyle returns the style of contents and the contents normalised to `\n` line endings without BOM.
+// A file uses CRLF line endings if the majority of its lines do.
+func detectTextStyle(contents []byte) (textStyle, []byte) {
+	var style textStyle
+
+	if bytes.HasPrefix(contents, utf8BOM) {
+		style.bom = true
+		contents = contents[len(utf8BOM):]
+	}
+
+	crlf := bytes.Count(contents, []byte("\r\n"))
+	if crlf > 0 && crlf*2 > bytes.Count(contents, []byte("\n")) {
+		style.crlf = true
+		contents = bytes.ReplaceAll(contents, []byte("\r\n"), []byte("\n"))
+	}
+
+	return style, contents
+}
+
+// apply restores the style, unless contents specify their own line endings or BOM.
+func (s textStyle) apply(contents []byte) []byte {
+	if s.crlf && !bytes.Contains(contents, []byte("\r\n")) {
+		contents = bytes.ReplaceAll(contents, []byte("\n"), []byte("\r\n"))
+	}
+
+	if s.bom && !bytes.HasPrefix(contents, utf8BOM) {
+		contents = append(bytes.Clone(utf8BOM), contents...)
+	}
+
+	return contents
+}
+
+// preserveFileInfo sets the mode and, if possible, the owner of the existing file on the staged modification.
+func (r *run) preserveFileInfo(file string) error {
+	info, err := fs.Stat(r.output, file)
+	if err != nil {
+		return fmt.Errorf("error reading file info of '%s': %w", file, err)
+	}
+
+	return r.setStagedFileInfo(file, info)
+}
+
+// setStagedFileInfo sets the mode and, if possible, the owner of info on the staged file.
+func (r *run) setStagedFileInfo(file string, info fs.FileInfo) error {
+	if chmodFS, ok := r.tmptfs.(ChmodFS); ok {
+		err := chmodFS.Chmod(file, info.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("error setting mode of '%s': %w", file, err)
+		}
+	}
+
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+
+	err := chownBestEffort(r.tmptfs, file, uid, gid)
+	if err != nil {
+		return fmt.Errorf("error setting owner of '%s': %w", file, err)
+	}
+
+	return nil
+}
+
+// chownBestEffort sets the owner of name if fsys implements [ChownFS]. Only privileged users can give files away,
+// so permission errors are ignored and the owner is kept on a best effort basis.
+func chownBestEffort(fsys OutputFS, name string, uid int, gid int) error {
+	chownFS, ok := fsys.(ChownFS)
+	if !ok {
+		return nil
+	}
+
+	err := chownFS.Chown(name, uid, gid)
+	if err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package drydock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_ModifyFile_PreserveFileInfo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	appendLine := ModifyFile("run.sh", func(contents []byte, w io.Writer) error {
+		_, err := w.Write(append(contents, []byte("echo done\n")...))
+		return err
+	})
+
+	t.Run("Mode", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o750))
+
+		err := NewGenerator(NewOSOutputFS(dir)).Generate(ctx, appendLine)
+		require.NoError(t, err)
+
+		info, err := os.Stat(filepath.Join(dir, "run.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+	})
+
+	t.Run("MapFSOutputFS", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"run.sh": &fstest.MapFile{Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs).Generate(ctx, appendLine)
+		require.NoError(t, err)
+
+		assert.Equal(t, "#!/bin/sh\necho done\n", string(tmpfs.MapFS["run.sh"].Data))
+		assert.Equal(t, os.FileMode(0o755), tmpfs.MapFS["run.sh"].Mode)
+	})
+
+	t.Run("Multiple Modifiers", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"run.sh": &fstest.MapFile{Data: []byte("#!/bin/sh\r\n"), Mode: 0o755},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithPreserveLineEndings(true)).Generate(ctx, appendLine, appendLine)
+		require.NoError(t, err)
+
+		assert.Equal(t, "#!/bin/sh\r\necho done\r\necho done\r\n", string(tmpfs.MapFS["run.sh"].Data))
+		assert.Equal(t, os.FileMode(0o755), tmpfs.MapFS["run.sh"].Mode)
+	})
+
+	t.Run("Multiple Modifiers OSOutputFS", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o750))
+
+		err := NewGenerator(NewOSOutputFS(dir)).Generate(ctx, appendLine, appendLine)
+		require.NoError(t, err)
+
+		info, err := os.Stat(filepath.Join(dir, "run.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+	})
+}
+
+func TestGenerator_Generate_ModifyFile_PreserveLineEndings(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	bom := string(utf8BOM)
+
+	tt := []struct {
+		name     string
+		existing string
+		modified string
+		expected string
+	}{
+		{name: "LF", existing: "a\nb\n", modified: "a\nc\n", expected: "a\nc\n"},
+		{name: "CRLF", existing: "a\r\nb\r\n", modified: "a\nc\n", expected: "a\r\nc\r\n"},
+		{name: "Mostly LF", existing: "a\r\nb\nc\n", modified: "a\nc\n", expected: "a\nc\n"},
+		{name: "BOM", existing: bom + "a\n", modified: "b\n", expected: bom + "b\n"},
+		{name: "BOM and CRLF", existing: bom + "a\r\nb\r\n", modified: "a\nc\n", expected: bom + "a\r\nc\r\n"},
+		{name: "Modifier specifies CRLF", existing: "a\nb\n", modified: "a\r\nc\r\n", expected: "a\r\nc\r\n"},
+		{name: "Modifier specifies BOM", existing: bom + "a\r\n", modified: bom + "b\r\n", expected: bom + "b\r\n"},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+				"file.txt": &fstest.MapFile{Data: []byte(tt.existing)},
+			}, baseDir: "."}
+
+			var received []byte
+			err := NewGenerator(tmpfs, WithPreserveLineEndings(true)).Generate(ctx,
+				ModifyFile("file.txt", func(contents []byte, w io.Writer) error {
+					received = bytes.Clone(contents)
+					_, err := w.Write([]byte(tt.modified))
+					return err
+				}),
+			)
+			require.NoError(t, err)
+
+			assert.NotContains(t, string(received), bom)
+			assert.Equal(t, tt.expected, string(tmpfs.MapFS["file.txt"].Data))
+		})
+	}
+}
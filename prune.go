@@ -0,0 +1,221 @@
+package drydock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"time"
+)
+
+// ManifestPath is the location, relative to the output root, where the [Generator] records
+// which files and directories it generated when pruning is enabled (see [WithPrune]).
+const ManifestPath = ".drydock/manifest.json"
+
+type manifest struct {
+	Files []string `json:"files"`
+	Dirs  []string `json:"dirs"`
+	// Headers are the files that were generated with a header marking them as generated, see [GeneratedHeader].
+	Headers []string `json:"headers,omitempty"`
+}
+
+func readManifest(fsys fs.FS) (*manifest, error) {
+	data, err := fs.ReadFile(fsys, ManifestPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &manifest{}, nil
+		}
+
+		return nil, fmt.Errorf("error reading manifest '%s': %w", ManifestPath, err)
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest '%s': %w", ManifestPath, err)
+	}
+
+	return &m, nil
+}
+
+func writeManifest(output OutputFS, m *manifest) error {
+	slices.Sort(m.Files)
+	slices.Sort(m.Dirs)
+	slices.Sort(m.Headers)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing manifest '%s': %w", ManifestPath, err)
+	}
+
+	err = output.Mkdir(path.Dir(ManifestPath))
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("error writing manifest '%s': %w", ManifestPath, err)
+	}
+
+	err = writeFile(output, ManifestPath, data)
+	if err != nil {
+		return fmt.Errorf("error writing manifest '%s': %w", ManifestPath, err)
+	}
+
+	return nil
+}
+
+// generatedFiles returns the files of the current run recorded in the manifest. Skipped existing files, see
+// [ConflictSkip], and modified files are only considered generated if a previous run created them.
+func (r *run) generatedFiles() []string {
+	files := make([]string, 0, len(r.tmpfiles))
+	for _, file := range r.tmpfiles {
+		if r.policies[file] != ConflictSkip || slices.Contains(r.prevManifest.Files, file) {
+			files = append(files, file)
+		}
+	}
+
+	for _, file := range r.tmpmodified {
+		if slices.Contains(r.prevManifest.Files, file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// staleFiles returns the files recorded in the previous manifest that are no longer generated,
+// except for files the user has taken over, see [run.takenOver].
+func (r *run) staleFiles() []string {
+	current := r.generatedFiles()
+
+	var stale []string
+	for _, file := range r.prevManifest.Files {
+		if !slices.Contains(current, file) && !r.takenOver(file) {
+			stale = append(stale, file)
+		}
+	}
+
+	return stale
+}
+
+// headerFiles returns the files written by the current run with a header marking them as generated.
+// Modifications never get a header.
+func (r *run) headerFiles() []string {
+	var files []string
+	for _, file := range r.tmpfiles {
+		if r.policies[file] != ConflictSkip && r.marksGenerated(file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// marksGenerated reports whether the file gets a header in the current run that marks it as generated.
+func (r *run) marksGenerated(file string) bool {
+	text := r.generatedHeaderText(file)
+	return text != "" && generatedCodePattern.MatchString(text)
+}
+
+// takenOver reports whether the generated header has been removed from a file that was generated with one marking
+// it as generated according to the previous manifest, or would get one in the current run, see [WithGeneratedHeader]
+// and [GeneratedHeader]. Following the Go convention, the user has taken over such a file and it is no longer pruned.
+func (r *run) takenOver(file string) bool {
+	if !slices.Contains(r.prevManifest.Headers, file) && !r.marksGenerated(file) {
+		return false
+	}
+
+	contents, err := fs.ReadFile(r.output, file)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := commentStyleFor(file); !ok || isBinary(contents) {
+		return false
+	}
+
+	return !IsGenerated(file, contents)
+}
+
+// pruneStale removes all files that were recorded in the previous manifest but are no longer part
+// of the current one, as well as any directories created by drydock that are left empty by doing so.
+// The current manifest is written afterwards.
+func (r *run) pruneStale(ctx context.Context) error {
+	prev := r.prevManifest
+
+	current := &manifest{Files: r.generatedFiles(), Dirs: make([]string, 0, len(r.tmpdirs)), Headers: r.headerFiles()}
+
+	// only directories created by drydock, in this or a previous run, are recorded and may be pruned
+	for dir := range r.tmpdirs {
+		if slices.Contains(r.result.DirsCreated, dir) || slices.Contains(prev.Dirs, dir) {
+			current.Dirs = append(current.Dirs, dir)
+		}
+	}
+
+	for _, file := range r.staleFiles() {
+		exists, err := fileExists(r.output, file)
+		if err != nil {
+			return fmt.Errorf("error pruning file '%s': %w", file, err)
+		}
+
+		if !exists {
+			continue
+		}
+
+		err = r.recordDelete(file)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+
+		err = r.output.Remove(file)
+		if err != nil {
+			return fmt.Errorf("error pruning file '%s': %w", file, err)
+		}
+
+		r.addResult(ctx, file, OutcomeDeleted, time.Since(start))
+
+		err = r.pruneEmptyParents(file, prev.Dirs, current.Dirs)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := r.recordWrite(ManifestPath)
+	if err != nil {
+		return err
+	}
+
+	return writeManifest(r.output, current)
+}
+
+// pruneEmptyParents removes the empty parent directories of file that were created by drydock according to
+// the previous manifest and are not part of the current tree. Directories created by the user are kept.
+func (r *run) pruneEmptyParents(file string, created []string, keep []string) error {
+	for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if !slices.Contains(created, dir) || slices.Contains(keep, dir) {
+			return nil
+		}
+
+		entries, err := fs.ReadDir(r.output, dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return fmt.Errorf("error pruning dir '%s': %w", dir, err)
+		}
+
+		if len(entries) != 0 {
+			return nil
+		}
+
+		err = r.output.Remove(dir)
+		if err != nil {
+			return fmt.Errorf("error pruning dir '%s': %w", dir, err)
+		}
+	}
+
+	return nil
+}
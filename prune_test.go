@@ -0,0 +1,206 @@
+package drydock
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Prune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		".git/HEAD":      &fstest.MapFile{Data: []byte("ref: refs/heads/main")},
+		"LOCAL_NOTES.md": &fstest.MapFile{Data: []byte("hand written")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithPrune(true))
+	err := g.Generate(ctx,
+		PlainFile("README.md", "# drydock"),
+		Dir("pkg",
+			Dir("cli", PlainFile("cli.go", "package cli")),
+			PlainFile("pkg.go", "package pkg"),
+		),
+	)
+	require.NoError(t, err)
+
+	assert.Contains(t, tmpfs.MapFS, ManifestPath)
+	assert.Contains(t, tmpfs.MapFS, "pkg/cli/cli.go")
+
+	g = NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithPrune(true))
+	result, err := g.GenerateWithResult(ctx,
+		PlainFile("README.md", "# drydock"),
+		Dir("pkg", PlainFile("pkg.go", "package pkg")),
+	)
+	require.NoError(t, err)
+
+	assert.NotContains(t, tmpfs.MapFS, "pkg/cli/cli.go")
+	assert.NotContains(t, tmpfs.MapFS, "pkg/cli")
+	assert.Contains(t, tmpfs.MapFS, "pkg/pkg.go")
+	assert.Contains(t, tmpfs.MapFS, "README.md")
+	assert.Contains(t, tmpfs.MapFS, ".git/HEAD")
+	assert.Contains(t, tmpfs.MapFS, "LOCAL_NOTES.md")
+	assert.Equal(t, 1, result.Count(OutcomeDeleted))
+	assert.Contains(t, result.Files, FileResult{Path: "pkg/cli/cli.go", Outcome: OutcomeDeleted})
+}
+
+func TestGenerator_Generate_Prune_KeepsNonEmptyDirs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithPrune(true))
+	err := g.Generate(ctx, Dir("cmd", PlainFile("main.go", "package main")))
+	require.NoError(t, err)
+
+	tmpfs.MapFS["cmd/user.go"] = &fstest.MapFile{Data: []byte("package main")}
+
+	g = NewGenerator(tmpfs, WithPrune(true))
+	err = g.Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+
+	assert.NotContains(t, tmpfs.MapFS, "cmd/main.go")
+	assert.Contains(t, tmpfs.MapFS, "cmd/user.go")
+	assert.Contains(t, tmpfs.MapFS, "cmd")
+}
+
+func TestGenerator_Generate_Prune_KeepsUserDirs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"docs": &fstest.MapFile{Mode: 0o755 | fs.ModeDir},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithPrune(true))
+	err := g.Generate(ctx, Dir("docs", Dir("api", PlainFile("index.md", "# API"))))
+	require.NoError(t, err)
+
+	err = NewGenerator(tmpfs, WithPrune(true)).Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+
+	assert.NotContains(t, tmpfs.MapFS, "docs/api/index.md")
+	assert.NotContains(t, tmpfs.MapFS, "docs/api")
+	assert.Contains(t, tmpfs.MapFS, "docs")
+}
+
+func TestGenerator_Generate_Prune_EmptyOutputDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithPrune(true)).Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+
+	err = NewGenerator(tmpfs, WithPrune(true), WithEmptyOutputDir(true)).Generate(ctx, PlainFile("main.go", "package main"))
+	require.NoError(t, err)
+
+	m, err := readManifest(tmpfs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, m.Files)
+}
+
+func TestGenerator_Generate_Prune_TakenOver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	files := []File{
+		PlainFile("kept.go", "package main\n"),
+		PlainFile("owned.go", "package main\n"),
+		PlainFile("stale.go", "package main\n"),
+	}
+
+	err := NewGenerator(tmpfs, WithPrune(true), WithGeneratedHeader(DefaultGeneratedHeader)).Generate(ctx, files...)
+	require.NoError(t, err)
+
+	tmpfs.MapFS["owned.go"] = &fstest.MapFile{Data: []byte("package main\n\n// edited by hand\n")}
+
+	err = NewGenerator(tmpfs, WithPrune(true), WithGeneratedHeader(DefaultGeneratedHeader), WithErrorOnExistingFile(false)).
+		Generate(ctx, files[0])
+	require.NoError(t, err)
+
+	assert.Contains(t, tmpfs.MapFS, "kept.go")
+	assert.Contains(t, tmpfs.MapFS, "owned.go")
+	assert.NotContains(t, tmpfs.MapFS, "stale.go")
+
+	m, err := readManifest(tmpfs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept.go"}, m.Files)
+}
+
+func TestGenerator_Generate_Prune_TakenOver_GeneratedHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithPrune(true)).Generate(ctx,
+		GeneratedHeader(PlainFile("owned.go", "package main\n")),
+		GeneratedHeader(Dir("gen", PlainFile("stale.go", "package gen\n"))),
+		PlainFile("plain.go", "package main\n"),
+	)
+	require.NoError(t, err)
+
+	m, err := readManifest(tmpfs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gen/stale.go", "owned.go"}, m.Headers)
+
+	tmpfs.MapFS["owned.go"] = &fstest.MapFile{Data: []byte("package main\n\n// edited by hand\n")}
+
+	err = NewGenerator(tmpfs, WithPrune(true)).Generate(ctx, PlainFile("README.md", "# drydock\n"))
+	require.NoError(t, err)
+
+	assert.Contains(t, tmpfs.MapFS, "owned.go")
+	assert.NotContains(t, tmpfs.MapFS, "gen/stale.go")
+	assert.NotContains(t, tmpfs.MapFS, "plain.go")
+}
+
+func TestGenerator_Generate_Prune_Skipped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"LICENSE": &fstest.MapFile{Data: []byte("hand written")},
+	}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithPrune(true)).Generate(ctx,
+		OnConflict(PlainFile("LICENSE", "generated"), ConflictSkip),
+		PlainFile("README.md", "# drydock"),
+	)
+	require.NoError(t, err)
+
+	m, err := readManifest(tmpfs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md"}, m.Files)
+
+	err = NewGenerator(tmpfs, WithPrune(true), WithErrorOnExistingFile(false)).Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hand written", string(tmpfs.MapFS["LICENSE"].Data))
+}
+
+func TestStaged_Ops_Prune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithPrune(true)).Generate(ctx, PlainFile("README.md", "# drydock"), PlainFile("old.go", "package old"))
+	require.NoError(t, err)
+
+	staged, err := NewGenerator(tmpfs, WithPrune(true), WithErrorOnExistingFile(false)).Stage(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = staged.Abort() })
+
+	assert.Contains(t, staged.Ops(), PendingOp{Kind: OpDeleteFile, Path: "old.go"})
+	assert.Contains(t, tmpfs.MapFS, "old.go")
+}
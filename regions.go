@@ -0,0 +1,165 @@
+package drydock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidUserRegion = errors.New("invalid user region")
+	ErrUserRegionRemoved = errors.New("user region removed from template")
+)
+
+// WithUserRegions keeps hand-written code in regenerated files. A user region is delimited by lines containing
+// `drydock:user-begin <id>` and `drydock:user-end <id>`, usually inside a comment:
+//
+//	// drydock:user-begin imports
+//	import "example.com/custom"
+//	// drydock:user-end imports
+//
+// When a generated file already exists, the body of every region in the existing file replaces the body of the
+// region with the same id in the generated file. Regions that are new in the template keep their generated body.
+// If a region with a non-blank body is missing from the generated file, generation fails with [ErrUserRegionRemoved]
+// instead of dropping the code. Existing files must still be allowed to be overwritten, see [WithErrorOnExistingFile]
+// and [OnConflict]. Modifications, see [ModifyFile], are not affected.
+func WithUserRegions(b bool) Option {
+	return func(g *Generator) {
+		g.userRegions = b
+	}
+}
+
+var userRegionMarker = regexp.MustCompile(`drydock:user-(begin|end)\s+([\w.-]+)`)
+
+// userRegion is a region of a file, start and end are the offsets of its body.
+type userRegion struct {
+	id    string
+	start int
+	end   int
+}
+
+// parseUserRegions returns the regions of contents in order. Regions can't be nested and ids must be unique.
+func parseUserRegions(contents []byte) ([]userRegion, error) {
+	var regions []userRegion
+	var open *userRegion
+	seen := map[string]bool{}
+
+	offset := 0
+	for _, line := range bytes.SplitAfter(contents, []byte("\n")) {
+		lineStart := offset
+		offset += len(line)
+
+		m := userRegionMarker.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		kind, id := string(m[1]), string(m[2])
+
+		switch {
+		case kind == "begin" && open != nil:
+			return nil, fmt.Errorf("%w: region '%s' starts before region '%s' ends", ErrInvalidUserRegion, id, open.id)
+		case kind == "begin" && seen[id]:
+			return nil, fmt.Errorf("%w: duplicate region '%s'", ErrInvalidUserRegion, id)
+		case kind == "begin":
+			seen[id] = true
+			open = &userRegion{id: id, start: offset}
+		case open == nil || open.id != id:
+			return nil, fmt.Errorf("%w: unexpected end of region '%s'", ErrInvalidUserRegion, id)
+		default:
+			open.end = lineStart
+			regions = append(regions, *open)
+			open = nil
+		}
+	}
+
+	if open != nil {
+		return nil, fmt.Errorf("%w: region '%s' is never closed", ErrInvalidUserRegion, open.id)
+	}
+
+	return regions, nil
+}
+
+// spliceUserRegions replaces the bodies of the regions in generated with those of the existing file.
+func spliceUserRegions(existing []byte, generated []byte) ([]byte, error) {
+	existingRegions, err := parseUserRegions(existing)
+	if err != nil {
+		return nil, fmt.Errorf("existing file: %w", err)
+	}
+
+	if len(existingRegions) == 0 {
+		return generated, nil
+	}
+
+	generatedRegions, err := parseUserRegions(generated)
+	if err != nil {
+		return nil, fmt.Errorf("generated file: %w", err)
+	}
+
+	bodies := make(map[string][]byte, len(existingRegions))
+	for _, r := range existingRegions {
+		bodies[r.id] = existing[r.start:r.end]
+	}
+
+	var spliced bytes.Buffer
+	prev := 0
+	for _, r := range generatedRegions {
+		body, ok := bodies[r.id]
+		if !ok {
+			continue
+		}
+
+		delete(bodies, r.id)
+
+		spliced.Write(generated[prev:r.start])
+		spliced.Write(body)
+		prev = r.end
+	}
+
+	spliced.Write(generated[prev:])
+
+	var removed []string
+	for _, r := range existingRegions {
+		if body, ok := bodies[r.id]; ok && len(bytes.TrimSpace(body)) != 0 {
+			removed = append(removed, r.id)
+		}
+	}
+
+	if len(removed) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUserRegionRemoved, strings.Join(removed, ", "))
+	}
+
+	return spliced.Bytes(), nil
+}
+
+// keepUserRegions wraps write to splice the user regions of the existing file into the generated contents.
+func (r *run) keepUserRegions(file string, write func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		existing, err := fs.ReadFile(r.output, file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return write(w)
+			}
+
+			return fmt.Errorf("error reading existing file '%s': %w", file, err)
+		}
+
+		var b bytes.Buffer
+		err = write(&b)
+		if err != nil {
+			return err
+		}
+
+		spliced, err := spliceUserRegions(existing, b.Bytes())
+		if err != nil {
+			return fmt.Errorf("error keeping user regions of '%s': %w", file, err)
+		}
+
+		_, err = w.Write(spliced)
+		return err
+	}
+}
@@ -0,0 +1,183 @@
+package drydock
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_UserRegions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpl := `package handler
+
+import (
+	"net/http"
+	// drydock:user-begin imports
+	// drydock:user-end imports
+)
+
+func {{ . }}(w http.ResponseWriter, r *http.Request) {
+	// drydock:user-begin body
+	w.WriteHeader(http.StatusNotImplemented)
+	// drydock:user-end body
+}
+`
+
+	existing := `package handler
+
+import (
+	"net/http"
+	// drydock:user-begin imports
+	"example.com/app/store"
+	// drydock:user-end imports
+)
+
+func ListUsers(w http.ResponseWriter, r *http.Request) {
+	// drydock:user-begin body
+	users := store.Users(r.Context())
+	writeJSON(w, users)
+	// drydock:user-end body
+}
+`
+
+	t.Run("Splice", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"handler.go": &fstest.MapFile{Data: []byte(existing)},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithUserRegions(true), WithErrorOnExistingFile(false)).Generate(ctx, TemplatedFileStr("handler.go", tmpl, "GetUsers"))
+		require.NoError(t, err)
+
+		assert.Equal(t, `package handler
+
+import (
+	"net/http"
+	// drydock:user-begin imports
+	"example.com/app/store"
+	// drydock:user-end imports
+)
+
+func GetUsers(w http.ResponseWriter, r *http.Request) {
+	// drydock:user-begin body
+	users := store.Users(r.Context())
+	writeJSON(w, users)
+	// drydock:user-end body
+}
+`, string(tmpfs.MapFS["handler.go"].Data))
+	})
+
+	t.Run("New File", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithUserRegions(true), WithErrorOnExistingFile(false)).Generate(ctx, TemplatedFileStr("handler.go", tmpl, "GetUsers"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(tmpfs.MapFS["handler.go"].Data), "w.WriteHeader(http.StatusNotImplemented)")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"handler.go": &fstest.MapFile{Data: []byte(existing)},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithErrorOnExistingFile(false)).Generate(ctx, TemplatedFileStr("handler.go", tmpl, "GetUsers"))
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(tmpfs.MapFS["handler.go"].Data), "store.Users")
+	})
+
+	t.Run("Removed Region", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"handler.go": &fstest.MapFile{Data: []byte(existing)},
+		}, baseDir: "."}
+
+		err := NewGenerator(tmpfs, WithUserRegions(true), WithErrorOnExistingFile(false)).Generate(ctx, PlainFile("handler.go", "package handler\n"))
+		assert.ErrorIs(t, err, ErrUserRegionRemoved)
+		assert.ErrorContains(t, err, "imports, body")
+
+		assert.Equal(t, existing, string(tmpfs.MapFS["handler.go"].Data))
+	})
+}
+
+func TestSpliceUserRegions(t *testing.T) {
+	tt := []struct {
+		name      string
+		existing  string
+		generated string
+		exp       string
+		err       error
+	}{
+		{
+			name:      "No Regions",
+			existing:  "old\n",
+			generated: "new\n",
+			exp:       "new\n",
+		},
+		{
+			name:      "New Region",
+			existing:  "# drydock:user-begin a\nuser a\n# drydock:user-end a\n",
+			generated: "# drydock:user-begin a\n# drydock:user-end a\n<!-- drydock:user-begin b -->\ndefault b\n<!-- drydock:user-end b -->\n",
+			exp:       "# drydock:user-begin a\nuser a\n# drydock:user-end a\n<!-- drydock:user-begin b -->\ndefault b\n<!-- drydock:user-end b -->\n",
+		},
+		{
+			name:      "Reordered",
+			existing:  "// drydock:user-begin a\nA\n// drydock:user-end a\n// drydock:user-begin b\nB\n// drydock:user-end b\n",
+			generated: "// drydock:user-begin b\n// drydock:user-end b\nmiddle\n// drydock:user-begin a\n// drydock:user-end a\n",
+			exp:       "// drydock:user-begin b\nB\n// drydock:user-end b\nmiddle\n// drydock:user-begin a\nA\n// drydock:user-end a\n",
+		},
+		{
+			name:      "CRLF",
+			existing:  "// drydock:user-begin a\r\nA\r\n// drydock:user-end a\r\n",
+			generated: "// drydock:user-begin a\r\n// drydock:user-end a\r\n",
+			exp:       "// drydock:user-begin a\r\nA\r\n// drydock:user-end a\r\n",
+		},
+		{
+			name:      "Removed Blank Region",
+			existing:  "/* drydock:user-begin a */\n\n/* drydock:user-end a */\n",
+			generated: "new\n",
+			exp:       "new\n",
+		},
+		{
+			name:      "Removed Region",
+			existing:  "/* drydock:user-begin a */\nA\n/* drydock:user-end a */\n",
+			generated: "new\n",
+			err:       ErrUserRegionRemoved,
+		},
+		{
+			name:      "Unclosed Region",
+			existing:  "// drydock:user-begin a\nA\n",
+			generated: "new\n",
+			err:       ErrInvalidUserRegion,
+		},
+		{
+			name:      "Nested Region",
+			existing:  "// drydock:user-begin a\n// drydock:user-begin b\n// drydock:user-end b\n// drydock:user-end a\n",
+			generated: "new\n",
+			err:       ErrInvalidUserRegion,
+		},
+		{
+			name:      "Duplicate Region",
+			existing:  "// drydock:user-begin a\nA\n// drydock:user-end a\n",
+			generated: "// drydock:user-begin a\n// drydock:user-end a\n// drydock:user-begin a\n// drydock:user-end a\n",
+			err:       ErrInvalidUserRegion,
+		},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			spliced, err := spliceUserRegions([]byte(tt.existing), []byte(tt.generated))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, string(spliced))
+		})
+	}
+}
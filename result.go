@@ -0,0 +1,103 @@
+package drydock
+
+import (
+	"context"
+	"time"
+)
+
+// FileOutcome describes what happened to a file during generation.
+type FileOutcome string
+
+const (
+	// OutcomeCreated is a new file that didn't exist in the output before.
+	OutcomeCreated FileOutcome = "created"
+	// OutcomeOverwritten is an existing file that was replaced by the generated file.
+	OutcomeOverwritten FileOutcome = "overwritten"
+	// OutcomeModified is an existing file that was changed by a [WriterToModify].
+	OutcomeModified FileOutcome = "modified"
+	// OutcomeUnchanged is an existing file whose contents are identical to the generated or modified contents.
+	OutcomeUnchanged FileOutcome = "unchanged"
+	// OutcomeSkipped is an existing file that was kept due to [ConflictSkip].
+	OutcomeSkipped FileOutcome = "skipped"
+	// OutcomeDeleted is a file removed by pruning, see [WithPrune].
+	OutcomeDeleted FileOutcome = "deleted"
+)
+
+// FileResult is the outcome of a single file.
+type FileResult struct {
+	Path         string      `json:"path"`
+	Outcome      FileOutcome `json:"outcome"`
+	BytesWritten int64       `json:"bytesWritten"`
+}
+
+// Timings of the individual phases of a generation.
+type Timings struct {
+	// Staging is the time spent rendering all files into the temporary directory.
+	Staging time.Duration `json:"staging"`
+	// PostProcessing is the time spent resolving conflicts, merging and backing up files.
+	PostProcessing time.Duration `json:"postProcessing"`
+	// Commit is the time spent moving the files into the output, including cleaning and pruning.
+	Commit time.Duration `json:"commit"`
+}
+
+// Expansion lists the files an [Expander] was expanded to, e.g. the matches of [ModifyGlob].
+type Expansion struct {
+	// Name is the path of the Expander, e.g. the pattern of [ModifyGlob] joined with its directory.
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+// GenerateResult describes the changes made to the output by [Generator.GenerateWithResult].
+type GenerateResult struct {
+	Files       []FileResult `json:"files"`
+	DirsCreated []string     `json:"dirsCreated"`
+	Expanded    []Expansion  `json:"expanded"`
+	Timings     Timings      `json:"timings"`
+}
+
+// Count returns the number of files with the given outcome.
+func (r *GenerateResult) Count(outcome FileOutcome) int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Outcome == outcome {
+			n++
+		}
+	}
+
+	return n
+}
+
+// BytesWritten returns the total number of bytes written to the output.
+func (r *GenerateResult) BytesWritten() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.BytesWritten
+	}
+
+	return n
+}
+
+// GenerateWithResult is like [Generator.Generate] but also returns the outcome of every file, the created directories
+// and the timings of each phase. When an error is returned, the result describes the changes made until then.
+func (g *Generator) GenerateWithResult(ctx context.Context, files ...File) (*GenerateResult, error) {
+	return g.run(ctx, files...)
+}
+
+// addResult records the outcome of the file and notifies the observers.
+func (r *run) addResult(ctx context.Context, file string, outcome FileOutcome, duration time.Duration) {
+	var written int64
+	eventType := EventFileCommitted
+
+	switch outcome {
+	case OutcomeCreated, OutcomeOverwritten, OutcomeModified, OutcomeUnchanged:
+		written = r.written[file]
+	case OutcomeSkipped:
+		eventType = EventFileSkipped
+	case OutcomeDeleted:
+		eventType = EventFileDeleted
+	}
+
+	r.result.Files = append(r.result.Files, FileResult{Path: file, Outcome: outcome, BytesWritten: written})
+
+	r.emit(ctx, Event{Type: eventType, Path: file, Size: written, Duration: duration, Outcome: outcome})
+}
@@ -0,0 +1,63 @@
+package drydock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_GenerateWithResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithPrune(true))
+	_, err := g.GenerateWithResult(ctx, PlainFile("stale.txt", "stale"))
+	require.NoError(t, err)
+
+	tmpfs.MapFS["README.md"] = &fstest.MapFile{Data: []byte("old readme")}
+	tmpfs.MapFS["LICENSE"] = &fstest.MapFile{Data: []byte("license")}
+	tmpfs.MapFS["same.txt"] = &fstest.MapFile{Data: []byte("same")}
+	tmpfs.MapFS["config.ini"] = &fstest.MapFile{Data: []byte("foo = bar")}
+
+	g = NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithPrune(true))
+	result, err := g.GenerateWithResult(ctx,
+		PlainFile("README.md", "new readme"),
+		OnConflict(PlainFile("LICENSE", "new license"), ConflictSkip),
+		PlainFile("same.txt", "same"),
+		Dir("cmd", PlainFile("main.go", "package main")),
+		ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(append(contents, []byte("\nbaz = bat")...))
+			return err
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []FileResult{
+		{Path: "README.md", Outcome: OutcomeOverwritten, BytesWritten: 10},
+		{Path: "LICENSE", Outcome: OutcomeSkipped},
+		{Path: "same.txt", Outcome: OutcomeUnchanged, BytesWritten: 4},
+		{Path: "cmd/main.go", Outcome: OutcomeCreated, BytesWritten: 12},
+		{Path: "config.ini", Outcome: OutcomeModified, BytesWritten: 19},
+		{Path: "stale.txt", Outcome: OutcomeDeleted},
+	}, result.Files)
+
+	assert.Equal(t, []string{"cmd"}, result.DirsCreated)
+	assert.Equal(t, 1, result.Count(OutcomeCreated))
+	assert.Equal(t, int64(45), result.BytesWritten())
+	assert.Positive(t, result.Timings.Staging)
+	assert.Positive(t, result.Timings.Commit)
+
+	encoded, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	var decoded GenerateResult
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, result, &decoded)
+}
@@ -0,0 +1,170 @@
+package drydock
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrStagedDone is returned by [Staged.Commit] if the staged tree was already committed or aborted.
+	ErrStagedDone = errors.New("staged tree was already committed or aborted")
+	// ErrAborted is the error of the [EventRollback] emitted by [Staged.Abort].
+	ErrAborted = errors.New("staged tree was aborted")
+)
+
+// OpKind is the kind of a [PendingOp].
+type OpKind string
+
+const (
+	// OpCreateDir creates a directory in the output, unless it already exists.
+	OpCreateDir OpKind = "create_dir"
+	// OpWriteFile moves a generated file into the output.
+	OpWriteFile OpKind = "write_file"
+	// OpModifyFile moves a modification of an existing file into the output, see [ModifyFile].
+	OpModifyFile OpKind = "modify_file"
+	// OpDeleteFile removes a file generated by a previous run that is no longer part of the tree, see [WithPrune].
+	OpDeleteFile OpKind = "delete_file"
+)
+
+// PendingOp is an operation [Staged.Commit] will perform on the output.
+// Conflicts are resolved when staging, so files skipped due to [ConflictSkip] have no pending write.
+type PendingOp struct {
+	Kind OpKind
+	Path string
+	// Size of the staged file in bytes.
+	Size int64
+}
+
+// Staged is a tree that was rendered into the staging area, but not yet committed to the output.
+// It is created by [Generator.Stage] and must be finished by calling either [Staged.Commit] or [Staged.Abort].
+type Staged struct {
+	run *run
+
+	mu   sync.Mutex
+	done bool
+}
+
+// Stage renders the files added using [Generator.Add] followed by files into a new staging area, without
+// changing the output. The staged tree can be inspected, e.g. to run tests, linters or ask for approval,
+// before it is committed using [Staged.Commit] or discarded using [Staged.Abort].
+// Conflicts with existing files are resolved while staging, including asking the [ConflictResolver],
+// so an unresolved conflict fails Stage without changing the output.
+// If [WithLock] is enabled, the output stays locked until then.
+func (g *Generator) Stage(ctx context.Context, files ...File) (*Staged, error) {
+	r, err := g.stage(ctx, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Staged{run: r}, nil
+}
+
+// FS returns the read-only staging area, rooted like the output.
+func (s *Staged) FS() fs.FS {
+	return &stagedFS{fsys: s.run.tmptfs}
+}
+
+// Ops returns the operations to be performed by [Staged.Commit], in order.
+func (s *Staged) Ops() []PendingOp {
+	ops := make([]PendingOp, len(s.run.tmpdirs), len(s.run.tmpdirs)+len(s.run.tmpfiles)+len(s.run.tmpmodified))
+	for dir, i := range s.run.tmpdirs {
+		ops[i] = PendingOp{Kind: OpCreateDir, Path: dir}
+	}
+
+	for _, file := range s.run.tmpfiles {
+		if s.run.policies[file] == ConflictSkip {
+			continue
+		}
+
+		ops = append(ops, PendingOp{Kind: OpWriteFile, Path: file, Size: s.run.written[file]})
+	}
+
+	for _, file := range s.run.tmpmodified {
+		ops = append(ops, PendingOp{Kind: OpModifyFile, Path: file, Size: s.run.written[file]})
+	}
+
+	if s.run.prune {
+		for _, file := range s.run.staleFiles() {
+			ops = append(ops, PendingOp{Kind: OpDeleteFile, Path: file})
+		}
+	}
+
+	return ops
+}
+
+// Commit moves the staged tree into the output and removes the staging area, like [Generator.GenerateWithResult].
+func (s *Staged) Commit(ctx context.Context) (*GenerateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return nil, ErrStagedDone
+	}
+
+	s.done = true
+
+	r := s.run
+	err := r.moveToOutput(ctx)
+
+	r.mu.Lock()
+	r.Generator.backupID = r.backupID
+	r.mu.Unlock()
+
+	return r.result, errors.Join(err, r.release())
+}
+
+// Abort discards the staged tree without changing the output.
+// Calling Abort after [Staged.Commit] does nothing, so it can be deferred.
+func (s *Staged) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return nil
+	}
+
+	s.done = true
+
+	s.run.emit(context.Background(), Event{Type: EventRollback, Err: ErrAborted})
+
+	return errors.Join(s.run.output.RemoveAll(s.run.tmpdir), s.run.release())
+}
+
+// release releases the lock taken by [Generator.Stage].
+func (r *run) release() error {
+	if r.unlock == nil {
+		return nil
+	}
+
+	return r.unlock()
+}
+
+// stagedFS is the read-only view of the staging area, without the files internal to drydock.
+type stagedFS struct {
+	fsys fs.FS
+}
+
+func (sfs *stagedFS) Open(name string) (fs.File, error) {
+	if name == tempDirOwnerFile {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return sfs.fsys.Open(name)
+}
+
+func (sfs *stagedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(sfs.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Clean(name) != "." {
+		return entries, nil
+	}
+
+	return slices.DeleteFunc(entries, func(e fs.DirEntry) bool { return e.Name() == tempDirOwnerFile }), nil
+}
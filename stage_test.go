@@ -0,0 +1,126 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Stage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	newFS := func() *MapFSOutputFS {
+		return &MapFSOutputFS{MapFS: fstest.MapFS{
+			"config.ini": &fstest.MapFile{Data: []byte("foo = bar")},
+		}, baseDir: "."}
+	}
+
+	files := []File{
+		PlainFile("README.md", "readme"),
+		Dir("cmd", PlainFile("main.go", "package main")),
+		ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(append(contents, []byte("\nbaz = bat")...))
+			return err
+		}),
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		tmpfs := newFS()
+
+		staged, err := NewGenerator(tmpfs).Stage(ctx, files...)
+		require.NoError(t, err)
+		defer staged.Abort() //nolint: errcheck
+
+		assert.NotContains(t, tmpfs.MapFS, "README.md")
+		assert.Equal(t, "foo = bar", string(tmpfs.MapFS["config.ini"].Data))
+
+		mainGo, err := fs.ReadFile(staged.FS(), "cmd/main.go")
+		require.NoError(t, err)
+		assert.Equal(t, "package main", string(mainGo))
+
+		var paths []string
+		err = fs.WalkDir(staged.FS(), ".", func(p string, _ fs.DirEntry, err error) error {
+			paths = append(paths, p)
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{".", "README.md", "cmd", "cmd/main.go", "config.ini"}, paths)
+
+		assert.Equal(t, []PendingOp{
+			{Kind: OpCreateDir, Path: "cmd"},
+			{Kind: OpWriteFile, Path: "README.md", Size: 6},
+			{Kind: OpWriteFile, Path: "cmd/main.go", Size: 12},
+			{Kind: OpModifyFile, Path: "config.ini", Size: 19},
+		}, staged.Ops())
+
+		result, err := staged.Commit(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Count(OutcomeCreated))
+
+		assert.Equal(t, "readme", string(tmpfs.MapFS["README.md"].Data))
+		assert.Equal(t, "foo = bar\nbaz = bat", string(tmpfs.MapFS["config.ini"].Data))
+		assertNotExists(t, tmpfs, "/tmp")
+
+		_, err = staged.Commit(ctx)
+		assert.ErrorIs(t, err, ErrStagedDone)
+		assert.NoError(t, staged.Abort())
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		tmpfs := newFS()
+
+		var events []Event
+		observer := ObserverFunc(func(_ context.Context, event Event) {
+			events = append(events, event)
+		})
+
+		staged, err := NewGenerator(tmpfs, WithLock(true), WithObserver(observer)).Stage(ctx, files...)
+		require.NoError(t, err)
+
+		require.NoError(t, staged.Abort())
+
+		assert.Equal(t, fstest.MapFS{"config.ini": &fstest.MapFile{Data: []byte("foo = bar")}}, tmpfs.MapFS)
+		assert.Equal(t, EventRollback, events[len(events)-1].Type)
+		assert.ErrorIs(t, events[len(events)-1].Err, ErrAborted)
+
+		_, err = staged.Commit(ctx)
+		assert.ErrorIs(t, err, ErrStagedDone)
+
+		// the lock was released
+		err = NewGenerator(tmpfs, WithLock(true), WithLockTimeout(time.Second)).Generate(ctx, PlainFile("README.md", "readme"))
+		require.NoError(t, err)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		tmpfs := newFS()
+
+		resolver := ConflictResolverFunc(func(context.Context, *Conflict) (ConflictPolicy, error) {
+			return ConflictSkip, nil
+		})
+
+		staged, err := NewGenerator(tmpfs, WithConflictResolver(resolver)).Stage(ctx,
+			OnConflict(PlainFile("config.ini", "foo = baz"), ConflictPrompt),
+			PlainFile("README.md", "readme"),
+		)
+		require.NoError(t, err)
+		defer staged.Abort() //nolint: errcheck
+
+		assert.Equal(t, []PendingOp{{Kind: OpWriteFile, Path: "README.md", Size: 6}}, staged.Ops())
+	})
+
+	t.Run("Unresolved Conflict", func(t *testing.T) {
+		tmpfs := newFS()
+
+		_, err := NewGenerator(tmpfs).Stage(ctx, PlainFile("config.ini", "foo = baz"))
+		require.ErrorIs(t, err, fs.ErrExist)
+
+		assert.Equal(t, fstest.MapFS{"config.ini": &fstest.MapFile{Data: []byte("foo = bar")}}, tmpfs.MapFS)
+	})
+}
@@ -0,0 +1,75 @@
+package drydock
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// SyncFS is an [OutputFS] that can flush files and directories to stable storage, like [os.File.Sync].
+// It is used by [WithSync].
+type SyncFS interface {
+	OutputFS
+
+	// Sync commits the contents of the file or directory at name to stable storage.
+	Sync(name string) error
+}
+
+// WithSync makes every write durable: each staged file is synced before it is moved into the output and each
+// directory containing a moved file or created directory is synced afterwards, so a power loss right after
+// [Generator.Generate] returns doesn't leave empty or missing files. The output must implement [SyncFS],
+// otherwise nothing is synced.
+func WithSync(b bool) Option {
+	return func(g *Generator) {
+		g.sync = b
+	}
+}
+
+func (g *Generator) syncFile(name string) error {
+	if !g.sync {
+		return nil
+	}
+
+	syncFS, ok := g.output.(SyncFS)
+	if !ok {
+		return nil
+	}
+
+	err := syncFS.Sync(name)
+	if err != nil {
+		return fmt.Errorf("error syncing '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// syncDirs syncs the parent directories of all moved files and created directories.
+func (r *run) syncDirs() error {
+	if !r.sync {
+		return nil
+	}
+
+	dirs := make([]string, 0, len(r.result.DirsCreated)+len(r.result.Files))
+	for _, dir := range r.result.DirsCreated {
+		dirs = append(dirs, path.Dir(dir))
+	}
+
+	for _, f := range r.result.Files {
+		switch f.Outcome {
+		case OutcomeCreated, OutcomeOverwritten, OutcomeModified:
+			dirs = append(dirs, path.Dir(f.Path))
+		case OutcomeUnchanged, OutcomeSkipped, OutcomeDeleted:
+		}
+	}
+
+	slices.Sort(dirs)
+
+	for _, dir := range slices.Compact(dirs) {
+		err := r.syncFile(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
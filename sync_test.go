@@ -0,0 +1,103 @@
+package drydock
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Generate_Sync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("Order", func(t *testing.T) {
+		output := &recordingFS{MapFSOutputFS: &MapFSOutputFS{MapFS: fstest.MapFS{
+			"LICENSE": &fstest.MapFile{Data: []byte("license")},
+		}, baseDir: "."}}
+
+		g := NewGenerator(output, WithSync(true))
+		err := g.Generate(ctx,
+			Dir("cmd", PlainFile("main.go", "package main")),
+			PlainFile("README.md", "readme"),
+			OnConflict(PlainFile("LICENSE", "new license"), ConflictSkip),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"sync " + path.Join(output.tmpdir, "cmd/main.go"),
+			"rename cmd",
+			"sync " + path.Join(output.tmpdir, "README.md"),
+			"rename README.md",
+			"sync .",
+			"sync cmd",
+		}, output.ops)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		output := &recordingFS{MapFSOutputFS: &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}}
+
+		g := NewGenerator(output)
+		err := g.Generate(ctx, PlainFile("README.md", "readme"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"rename README.md"}, output.ops)
+	})
+
+	t.Run("Journal", func(t *testing.T) {
+		output := &recordingFS{MapFSOutputFS: &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}}
+
+		g := NewGenerator(output, WithSync(true), WithJournal(true))
+		err := g.Generate(ctx, PlainFile("README.md", "readme"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"sync " + journalPath(),
+			"sync " + JournalDir,
+			"sync " + journalPath(),
+			"sync " + path.Join(output.tmpdir, "README.md"),
+			"rename README.md",
+			"sync " + journalPath(),
+			"sync .",
+		}, output.ops)
+	})
+
+	t.Run("OSOutputFS", func(t *testing.T) {
+		output := NewOSOutputFS(t.TempDir())
+
+		g := NewGenerator(output, WithSync(true))
+		err := g.Generate(ctx, Dir("cmd", PlainFile("main.go", "package main")))
+		require.NoError(t, err)
+
+		contents, err := output.(fs.ReadFileFS).ReadFile("cmd/main.go")
+		require.NoError(t, err)
+		assert.Equal(t, "package main", string(contents))
+	})
+}
+
+// recordingFS records all syncs and renames.
+type recordingFS struct {
+	*MapFSOutputFS
+	tmpdir string
+	ops    []string
+}
+
+func (fsys *recordingFS) MkdirTemp(pattern string) (OutputFS, string, error) {
+	tmpfs, tmpdir, err := fsys.MapFSOutputFS.MkdirTemp(pattern)
+	fsys.tmpdir = tmpdir
+	return tmpfs, tmpdir, err
+}
+
+func (fsys *recordingFS) Sync(name string) error {
+	fsys.ops = append(fsys.ops, "sync "+name)
+	return fsys.MapFSOutputFS.Sync(name)
+}
+
+func (fsys *recordingFS) Rename(oldpath string, newpath string) error {
+	fsys.ops = append(fsys.ops, "rename "+newpath)
+	return fsys.MapFSOutputFS.Rename(oldpath, newpath)
+}
@@ -0,0 +1,149 @@
+package drydock
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	defaultLeftDelim  = "{{"
+	defaultRightDelim = "}}"
+)
+
+// parseTemplate parses text with the given delimiters, empty delimiters default to `{{` and `}}`.
+// The [FuncMap] and funcs are available in the template.
+// Verbatim blocks are replaced by string literals before parsing, see [TemplatedFileStrDelims].
+func parseTemplate(name string, text string, left string, right string, funcs template.FuncMap) (*template.Template, error) {
+	if left == "" {
+		left = defaultLeftDelim
+	}
+
+	if right == "" {
+		right = defaultRightDelim
+	}
+
+	text, err := replaceVerbatimBlocks(text, left, right)
+	if err != nil {
+		return nil, fmt.Errorf("template: %s: %w", name, err)
+	}
+
+	return template.New(name).Delims(left, right).Funcs(FuncMap()).Funcs(funcs).Parse(text)
+}
+
+// actionPatterns caches the compiled [actionPattern] per delimiter pair.
+var actionPatterns sync.Map
+
+type delims struct {
+	left  string
+	right string
+}
+
+// actionPattern returns a regular expression matching an action and capturing its first word. String, raw string
+// and character literals as well as comments are skipped, so a right delimiter inside them doesn't end the action.
+func actionPattern(left string, right string) *regexp.Regexp {
+	key := delims{left: left, right: right}
+	if re, ok := actionPatterns.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(left) + `-?\s*(\w*)` +
+		`(?:/\*.*?\*/|"(?:[^"\\\n]|\\.)*"|` + "`[^`]*`" + `|'(?:[^'\\\n]|\\.)*'|.)*?` + regexp.QuoteMeta(right))
+
+	actual, _ := actionPatterns.LoadOrStore(key, re)
+	return actual.(*regexp.Regexp)
+}
+
+// replaceVerbatimBlocks replaces every `{{verbatim}}...{{end}}` block with an action printing its body as a string
+// literal. Actions in the body are not evaluated, but their `{{if}}`, `{{range}}`, `{{with}}`, `{{block}}` and
+// `{{define}}` blocks have to be balanced, so that the body ends at the matching `{{end}}`.
+// A right delimiter in a literal or comment, like in `{{ "}}" }}`, doesn't end an action.
+func replaceVerbatimBlocks(text string, left string, right string) (string, error) {
+	actions := actionPattern(left, right)
+
+	var b strings.Builder
+	for {
+		begin := findVerbatimBegin(actions, text)
+		if begin == nil {
+			b.WriteString(text)
+			return b.String(), nil
+		}
+
+		b.WriteString(text[:begin[0]])
+
+		beginTag := text[begin[0]:begin[1]]
+		text = text[begin[1]:]
+
+		end := findVerbatimEnd(actions, text)
+		if end == nil {
+			return "", errors.New("unclosed verbatim block")
+		}
+
+		endTag := text[end[0]:end[1]]
+		body := text[:end[0]]
+		text = text[end[1]:]
+
+		if trimRight(beginTag, right) {
+			body = strings.TrimLeft(body, " \t\r\n")
+		}
+
+		if trimLeft(endTag, left) {
+			body = strings.TrimRight(body, " \t\r\n")
+		}
+
+		if trimLeft(beginTag, left) {
+			b.WriteString(left + "- ")
+		} else {
+			b.WriteString(left)
+		}
+
+		b.WriteString(strconv.Quote(body))
+
+		if trimRight(endTag, right) {
+			b.WriteString(" -" + right)
+		} else {
+			b.WriteString(right)
+		}
+	}
+}
+
+// findVerbatimBegin returns the location of the first `{{verbatim}}` action.
+func findVerbatimBegin(actions *regexp.Regexp, text string) []int {
+	for _, m := range actions.FindAllStringSubmatchIndex(text, -1) {
+		if text[m[2]:m[3]] == "verbatim" {
+			return m[:2]
+		}
+	}
+
+	return nil
+}
+
+// findVerbatimEnd returns the location of the `{{end}}` action closing the verbatim block text starts in.
+func findVerbatimEnd(actions *regexp.Regexp, text string) []int {
+	depth := 1
+	for _, m := range actions.FindAllStringSubmatchIndex(text, -1) {
+		switch text[m[2]:m[3]] {
+		case "if", "range", "with", "block", "define", "verbatim":
+			depth++
+		case "end":
+			depth--
+			if depth == 0 {
+				return m[:2]
+			}
+		}
+	}
+
+	return nil
+}
+
+func trimLeft(tag string, left string) bool {
+	return strings.HasPrefix(tag[len(left):], "-")
+}
+
+func trimRight(tag string, right string) bool {
+	return strings.HasSuffix(tag[:len(tag)-len(right)], "-")
+}
@@ -0,0 +1,90 @@
+package drydock
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatedFileStrDelims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tt := []struct {
+		name  string
+		tmpl  string
+		left  string
+		right string
+		data  any
+		exp   string
+		err   string
+	}{
+		{
+			name:  "Custom Delims",
+			tmpl:  "name: [[ .Name ]]\nrun: echo ${{ github.ref }}\n",
+			left:  "[[",
+			right: "]]",
+			data:  map[string]string{"Name": "ci"},
+			exp:   "name: ci\nrun: echo ${{ github.ref }}\n",
+		},
+		{
+			name: "Default Delims",
+			tmpl: "name: {{ .Name }}\n",
+			data: map[string]string{"Name": "ci"},
+			exp:  "name: ci\n",
+		},
+		{
+			name: "Verbatim",
+			tmpl: "name: {{ .Name }}\n{{verbatim}}image: {{ .Values.image | quote }}\n{{ if .Values.enabled }}on{{ end }}{{end}}\n",
+			data: map[string]string{"Name": "chart"},
+			exp:  "name: chart\nimage: {{ .Values.image | quote }}\n{{ if .Values.enabled }}on{{ end }}\n",
+		},
+		{
+			name: "Verbatim Trim",
+			tmpl: "a\n{{- verbatim -}}\n  {{ .Raw }}\n{{- end }}\nb",
+			exp:  "a{{ .Raw }}\nb",
+		},
+		{
+			name:  "Verbatim Custom Delims",
+			tmpl:  "<% .Name %>: <% verbatim %>{{ .Values.x }} \"quoted\" \\n<% end %>",
+			left:  "<%",
+			right: "%>",
+			data:  map[string]string{"Name": "x"},
+			exp:   "x: {{ .Values.x }} \"quoted\" \\n",
+		},
+		{
+			name: "Multiple Verbatim",
+			tmpl: "{{verbatim}}{{ a }}{{end}}-{{ . }}-{{verbatim}}{{ b }}{{end}}",
+			data: "mid",
+			exp:  "{{ a }}-mid-{{ b }}",
+		},
+		{
+			name: "Verbatim Delims In Literals",
+			tmpl: "{{ \"}}\" }}{{verbatim}}{{ print \"}}{{end}}\" `}}` '}' /* }} */ }}{{end}}",
+			exp:  "}}{{ print \"}}{{end}}\" `}}` '}' /* }} */ }}",
+		},
+		{
+			name: "Unclosed Verbatim",
+			tmpl: "{{verbatim}}{{ a }}",
+			err:  "unclosed verbatim block",
+		},
+	}
+
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+			err := NewGenerator(tmpfs).Generate(ctx, TemplatedFileStrDelims("out.txt", tt.tmpl, tt.left, tt.right, tt.data))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, string(tmpfs.MapFS["out.txt"].Data))
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package drydock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UndoDir is the directory, relative to the output root, in which the [Generator] records the last run
+// when [WithUndo] is enabled.
+const UndoDir = ".drydock/undo"
+
+var (
+	ErrNoUndoRecord = errors.New("no undo record")
+	// ErrEditedSinceGeneration is returned by [Undo] when files of the last run have been changed since.
+	ErrEditedSinceGeneration = errors.New("files edited since generation")
+)
+
+const (
+	undoCreated  = "created"
+	undoReplaced = "replaced"
+	undoDeleted  = "deleted"
+)
+
+type undoRecord struct {
+	Files []undoEntry `json:"files"`
+	Dirs  []string    `json:"dirs"`
+	// Preimages is the dir in [UndoDir] containing the previous contents of the files.
+	Preimages string `json:"preimages,omitempty"`
+}
+
+type undoEntry struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	// Hash of the contents written by the run, used to detect later edits.
+	Hash string `json:"hash,omitempty"`
+	// Mode and owner of the previous file, restored along with its contents.
+	Mode fs.FileMode `json:"mode,omitempty"`
+	UID  *int        `json:"uid,omitempty"`
+	GID  *int        `json:"gid,omitempty"`
+}
+
+// WithUndo records the created files and directories as well as the previous contents of all
+// overwritten, modified and pruned files in [UndoDir], so the run can be reverted using [Undo].
+// Each run replaces the record of the previous one once it has been committed successfully. Files removed by [WithEmptyOutputDir] are not recorded.
+func WithUndo(b bool) Option {
+	return func(g *Generator) {
+		g.undo = b
+	}
+}
+
+// Undo reverts the last run of the [Generator] on the output using the record written by [Generator.Generate].
+// See [Undo] for details.
+func (g *Generator) Undo(ctx context.Context, skipEdited bool) ([]string, error) {
+	return Undo(ctx, g.output, skipEdited)
+}
+
+// Undo reverts the last run recorded in [UndoDir]: created files and directories are removed and
+// overwritten, modified or pruned files are restored.
+// If files have been edited since the run, Undo refuses with [ErrEditedSinceGeneration] without changing anything,
+// unless skipEdited is set, in which case the edited files are left alone and returned.
+func Undo(ctx context.Context, output OutputFS, skipEdited bool) ([]string, error) {
+	record, err := readUndoRecord(output)
+	if err != nil {
+		return nil, err
+	}
+
+	edited, err := findEdited(output, record)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(edited) != 0 && !skipEdited {
+		return edited, fmt.Errorf("%w: %s", ErrEditedSinceGeneration, strings.Join(edited, ", "))
+	}
+
+	for _, entry := range record.Files {
+		select {
+		case <-ctx.Done():
+			return edited, ctx.Err()
+		default:
+		}
+
+		if slices.Contains(edited, entry.Path) {
+			continue
+		}
+
+		err = undoEntryChange(output, record, entry)
+		if err != nil {
+			return edited, err
+		}
+	}
+
+	dirs := slices.Clone(record.Dirs)
+	slices.SortFunc(dirs, func(a, b string) int {
+		return strings.Count(b, "/") - strings.Count(a, "/")
+	})
+
+	for _, dir := range dirs {
+		err = removeIfEmpty(output, dir)
+		if err != nil {
+			return edited, fmt.Errorf("error undoing dir '%s': %w", dir, err)
+		}
+	}
+
+	err = output.RemoveAll(UndoDir)
+	if err != nil {
+		return edited, fmt.Errorf("error removing undo record: %w", err)
+	}
+
+	return edited, nil
+}
+
+func undoEntryChange(output OutputFS, record *undoRecord, entry undoEntry) error {
+	var err error
+
+	switch entry.Action {
+	case undoCreated:
+		err = output.Remove(entry.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	case undoReplaced, undoDeleted:
+		err = copyFile(output, record.preimagePath(entry.Path), entry.Path)
+		if err == nil {
+			err = restoreFileInfo(output, entry)
+		}
+	default:
+		err = fmt.Errorf("unknown action '%s'", entry.Action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error undoing file '%s': %w", entry.Path, err)
+	}
+
+	return nil
+}
+
+// restoreFileInfo sets the mode and, if possible, the owner recorded for the previous file.
+func restoreFileInfo(output OutputFS, entry undoEntry) error {
+	if chmodFS, ok := output.(ChmodFS); ok && entry.Mode != 0 {
+		err := chmodFS.Chmod(entry.Path, entry.Mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if entry.UID == nil || entry.GID == nil {
+		return nil
+	}
+
+	return chownBestEffort(output, entry.Path, *entry.UID, *entry.GID)
+}
+
+func findEdited(output OutputFS, record *undoRecord) ([]string, error) {
+	var edited []string
+
+	for _, entry := range record.Files {
+		if entry.Action == undoDeleted {
+			exists, err := fileExists(output, entry.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				edited = append(edited, entry.Path)
+			}
+
+			continue
+		}
+
+		hash, err := hashFile(output, entry.Path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				edited = append(edited, entry.Path)
+				continue
+			}
+
+			return nil, err
+		}
+
+		if hash != entry.Hash {
+			edited = append(edited, entry.Path)
+		}
+	}
+
+	return edited, nil
+}
+
+func readUndoRecord(output OutputFS) (*undoRecord, error) {
+	data, err := fs.ReadFile(output, path.Join(UndoDir, "record.json"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoUndoRecord
+		}
+
+		return nil, fmt.Errorf("error reading undo record: %w", err)
+	}
+
+	var record undoRecord
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, fmt.Errorf("error reading undo record: %w", err)
+	}
+
+	return &record, nil
+}
+
+// preimagePath returns the path of the previous contents of file. Records without a preimages dir
+// have been written before the dir was part of the record.
+func (record *undoRecord) preimagePath(file string) string {
+	preimages := record.Preimages
+	if preimages == "" {
+		preimages = "preimages"
+	}
+
+	return path.Join(UndoDir, preimages, file)
+}
+
+// preimageEntry copies the file to the preimages of the record and returns an entry with its mode and owner.
+func (record *undoRecord) preimageEntry(output OutputFS, file string, action string) (undoEntry, error) {
+	info, err := fs.Stat(output, file)
+	if err != nil {
+		return undoEntry{}, err
+	}
+
+	err = copyFile(output, file, record.preimagePath(file))
+	if err != nil {
+		return undoEntry{}, err
+	}
+
+	entry := undoEntry{Path: file, Action: action, Mode: info.Mode().Perm()}
+	if uid, gid, ok := fileOwner(info); ok {
+		entry.UID, entry.GID = &uid, &gid
+	}
+
+	return entry, nil
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	contents, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(contents)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// beginUndoRecord starts a new record. The previous contents of files are copied to a new preimages dir,
+// the record of the previous run stays in place until [run.finishUndoRecord] replaces it.
+func (r *run) beginUndoRecord() error {
+	if !r.undo {
+		return nil
+	}
+
+	r.undoRecord = &undoRecord{Preimages: fmt.Sprintf("preimages-%d", time.Now().UnixNano())}
+
+	return nil
+}
+
+// abortUndoRecord removes the preimages of a record that is never finished, keeping the previous record.
+func (r *run) abortUndoRecord() {
+	if r.undoRecord == nil {
+		return
+	}
+
+	r.output.RemoveAll(path.Join(UndoDir, r.undoRecord.Preimages)) //nolint: errcheck
+	r.undoRecord = nil
+}
+
+// recordWrite must be called before file is written to the output.
+func (r *run) recordWrite(file string) error {
+	if r.undoRecord == nil || r.undoRecorded(file) {
+		return nil
+	}
+
+	exists, err := fileExists(r.output, file)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		r.recordCreate(file)
+		return nil
+	}
+
+	entry, err := r.undoRecord.preimageEntry(r.output, file, undoReplaced)
+	if err != nil {
+		return fmt.Errorf("error recording file '%s' for undo: %w", file, err)
+	}
+
+	r.undoRecord.Files = append(r.undoRecord.Files, entry)
+
+	return nil
+}
+
+// recordCreate records a file that doesn't exist in the output yet.
+func (r *run) recordCreate(file string) {
+	if r.undoRecord != nil {
+		r.undoRecord.Files = append(r.undoRecord.Files, undoEntry{Path: file, Action: undoCreated})
+	}
+}
+
+// recordDelete must be called before file is removed from the output.
+func (r *run) recordDelete(file string) error {
+	if r.undoRecord == nil {
+		return nil
+	}
+
+	for i, entry := range r.undoRecord.Files {
+		if entry.Path == file && entry.Action == undoCreated {
+			r.undoRecord.Files = slices.Delete(r.undoRecord.Files, i, i+1)
+			return nil
+		}
+	}
+
+	entry, err := r.undoRecord.preimageEntry(r.output, file, undoDeleted)
+	if err != nil {
+		return fmt.Errorf("error recording file '%s' for undo: %w", file, err)
+	}
+
+	r.undoRecord.Files = append(r.undoRecord.Files, entry)
+
+	return nil
+}
+
+func (r *run) recordDir(dir string) {
+	if r.undoRecord != nil {
+		r.undoRecord.Dirs = append(r.undoRecord.Dirs, dir)
+	}
+}
+
+func (r *run) undoRecorded(file string) bool {
+	return slices.ContainsFunc(r.undoRecord.Files, func(e undoEntry) bool {
+		return e.Path == file
+	})
+}
+
+// finishUndoRecord hashes the written files and replaces the previous record by renaming the new one over it,
+// then removes the preimages of the previous record.
+func (r *run) finishUndoRecord() error {
+	if r.undoRecord == nil {
+		return nil
+	}
+
+	for i, entry := range r.undoRecord.Files {
+		if entry.Action == undoDeleted {
+			continue
+		}
+
+		hash, err := hashFile(r.output, entry.Path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return fmt.Errorf("error recording file '%s' for undo: %w", entry.Path, err)
+		}
+
+		r.undoRecord.Files[i].Hash = hash
+	}
+
+	data, err := json.MarshalIndent(r.undoRecord, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing undo record: %w", err)
+	}
+
+	err = mkdirAll(r.output, UndoDir)
+	if err != nil {
+		return fmt.Errorf("error writing undo record: %w", err)
+	}
+
+	recordPath := path.Join(UndoDir, "record.json")
+
+	err = writeFile(r.output, recordPath+".new", data)
+	if err != nil {
+		return fmt.Errorf("error writing undo record: %w", err)
+	}
+
+	err = r.output.Rename(recordPath+".new", recordPath)
+	if err != nil {
+		return fmt.Errorf("error writing undo record: %w", err)
+	}
+
+	preimages := r.undoRecord.Preimages
+	r.undoRecord = nil
+
+	entries, err := fs.ReadDir(r.output, UndoDir)
+	if err != nil {
+		return fmt.Errorf("error removing previous undo record: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.Name() == "." || e.Name() == "record.json" || e.Name() == preimages {
+			continue
+		}
+
+		err = r.output.RemoveAll(path.Join(UndoDir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("error removing previous undo record: %w", err)
+		}
+	}
+
+	return nil
+}
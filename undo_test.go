@@ -0,0 +1,144 @@
+package drydock
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Undo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md":  &fstest.MapFile{Data: []byte("old readme")},
+		"config.ini": &fstest.MapFile{Data: []byte("foo = bar")},
+		"user.txt":   &fstest.MapFile{Data: []byte("user file")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithUndo(true))
+	err := g.Generate(ctx,
+		PlainFile("README.md", "new readme"),
+		Dir("cmd", Dir("cli", PlainFile("main.go", "package main"))),
+		ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(append(contents, []byte("\nbaz = bat")...))
+			return err
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "new readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Contains(t, tmpfs.MapFS, "cmd/cli/main.go")
+
+	edited, err := g.Undo(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, edited)
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "foo = bar", string(tmpfs.MapFS["config.ini"].Data))
+	assert.Equal(t, "user file", string(tmpfs.MapFS["user.txt"].Data))
+	assert.NotContains(t, tmpfs.MapFS, "cmd/cli/main.go")
+	assert.NotContains(t, tmpfs.MapFS, "cmd/cli")
+	assert.NotContains(t, tmpfs.MapFS, "cmd")
+
+	_, err = Undo(ctx, tmpfs, false)
+	assert.ErrorIs(t, err, ErrNoUndoRecord)
+}
+
+func TestGenerator_Undo_Edited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md": &fstest.MapFile{Data: []byte("old readme")},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithUndo(true))
+	err := g.Generate(ctx, PlainFile("README.md", "new readme"), PlainFile("main.go", "package main"))
+	require.NoError(t, err)
+
+	tmpfs.MapFS["main.go"] = &fstest.MapFile{Data: []byte("package main // edited")}
+
+	edited, err := Undo(ctx, tmpfs, false)
+	require.ErrorIs(t, err, ErrEditedSinceGeneration)
+	assert.Equal(t, []string{"main.go"}, edited)
+	assert.Equal(t, "new readme", string(tmpfs.MapFS["README.md"].Data))
+
+	edited, err = Undo(ctx, tmpfs, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, edited)
+	assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+	assert.Equal(t, "package main // edited", string(tmpfs.MapFS["main.go"].Data))
+}
+
+func TestGenerator_Undo_Prune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithPrune(true))
+	err := g.Generate(ctx, PlainFile("README.md", "# drydock"), Dir("pkg", PlainFile("pkg.go", "package pkg")))
+	require.NoError(t, err)
+
+	manifest := string(tmpfs.MapFS[ManifestPath].Data)
+
+	g = NewGenerator(tmpfs, WithPrune(true), WithErrorOnExistingFile(false), WithUndo(true))
+	err = g.Generate(ctx, PlainFile("README.md", "# drydock"))
+	require.NoError(t, err)
+	assert.NotContains(t, tmpfs.MapFS, "pkg/pkg.go")
+
+	_, err = g.Undo(ctx, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "package pkg", string(tmpfs.MapFS["pkg/pkg.go"].Data))
+	assert.Equal(t, manifest, string(tmpfs.MapFS[ManifestPath].Data))
+}
+
+func TestGenerator_Undo_FailedRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"README.md": &fstest.MapFile{Data: []byte("old readme")},
+	}, baseDir: "."}
+
+	err := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithUndo(true)).Generate(ctx, PlainFile("README.md", "new readme"))
+	require.NoError(t, err)
+
+	err = NewGenerator(tmpfs, WithUndo(true)).Generate(ctx, PlainFile("main.go", "package main"), PlainFile("README.md", "newer readme"))
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	_, err = Undo(ctx, tmpfs, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "old readme", string(tmpfs.MapFS["README.md"].Data))
+
+	for p := range tmpfs.MapFS {
+		assert.NotContains(t, p, UndoDir)
+	}
+}
+
+func TestGenerator_Undo_Mode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+		"run.sh": &fstest.MapFile{Data: []byte("#!/bin/sh\necho old\n"), Mode: 0o755},
+	}, baseDir: "."}
+
+	g := NewGenerator(tmpfs, WithErrorOnExistingFile(false), WithUndo(true))
+	err := g.Generate(ctx, PlainFile("run.sh", "#!/bin/sh\necho new\n"))
+	require.NoError(t, err)
+
+	_, err = g.Undo(ctx, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "#!/bin/sh\necho old\n", string(tmpfs.MapFS["run.sh"].Data))
+	assert.Equal(t, fs.FileMode(0o755), tmpfs.MapFS["run.sh"].Mode.Perm())
+}
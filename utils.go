@@ -3,13 +3,17 @@ package drydock
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"os"
 	"path"
 )
 
 var ErrCleaningOutputDir = errors.New("error cleaning output dir")
 
-func cleanDir(rootFS OutputFS, dir string) error {
+// cleanDir removes all entries of dir, except those matched by keep.
+// Directories containing kept entries are cleaned recursively instead of being removed.
+func cleanDir(rootFS OutputFS, dir string, keep *keepList) error {
 	f, err := rootFS.Open(dir)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -20,6 +24,7 @@ func cleanDir(rootFS OutputFS, dir string) error {
 	}
 
 	stat, err := f.Stat()
+	f.Close()
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
 	}
@@ -28,30 +33,101 @@ func cleanDir(rootFS OutputFS, dir string) error {
 		return fmt.Errorf("%w: can't clean dir %s: %[1]s is not a directory", ErrCleaningOutputDir, dir)
 	}
 
-	var entries []fs.DirEntry
+	entries, err := fs.ReadDir(rootFS, dir)
+	if err != nil {
+		if keep.empty() {
+			return rootFS.RemoveAll(dir)
+		}
+
+		return fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
+	}
+
+	for _, e := range entries {
+		if e.Name() == "." {
+			continue
+		}
+
+		entryPath := path.Join(dir, e.Name())
 
-	switch dirFS := rootFS.(type) {
-	case fs.ReadDirFS:
-		entries, err = dirFS.ReadDir(dir)
-	case fs.ReadDirFile:
-		entries, err = dirFS.ReadDir(0)
-	default:
-		err = rootFS.RemoveAll(".")
+		if keep.match(entryPath, e.IsDir()) {
+			continue
+		}
+
+		if e.IsDir() && !keep.empty() {
+			err = cleanDir(rootFS, entryPath, keep)
+			if err != nil {
+				return err
+			}
+
+			err = removeIfEmpty(rootFS, entryPath)
+		} else {
+			err = rootFS.RemoveAll(entryPath)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
+		}
 	}
 
+	return nil
+}
+
+// cleanedFiles returns the files [cleanDir] removes from dir.
+func cleanedFiles(rootFS fs.FS, dir string, keep *keepList) ([]string, error) {
+	entries, err := fs.ReadDir(rootFS, dir)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
 	}
 
+	var files []string
 	for _, e := range entries {
 		if e.Name() == "." {
 			continue
 		}
 
-		err = rootFS.RemoveAll(path.Join(dir, e.Name()))
+		entryPath := path.Join(dir, e.Name())
+
+		if !keep.empty() && keep.match(entryPath, e.IsDir()) {
+			continue
+		}
+
+		if !e.IsDir() {
+			files = append(files, entryPath)
+			continue
+		}
+
+		nested, err := cleanedFiles(rootFS, entryPath, keep)
 		if err != nil {
-			return fmt.Errorf("%w: %w", ErrCleaningOutputDir, err)
+			return nil, err
 		}
+
+		files = append(files, nested...)
+	}
+
+	return files, nil
+}
+
+func removeIfEmpty(rootFS OutputFS, dir string) error {
+	entries, err := fs.ReadDir(rootFS, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(entries) != 0 {
+		return nil
+	}
+
+	err = rootFS.Remove(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
 	}
 
 	return nil
@@ -69,3 +145,77 @@ func fileExists(rootFS fs.FS, name string) (bool, error) {
 
 	return true, f.Close()
 }
+
+func writeFile(rootFS OutputFS, name string, data []byte) error {
+	f, err := rootFS.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w, ok := f.(io.Writer)
+	if !ok {
+		return errors.Join(fmt.Errorf("file %s opened with FS %T is not io.Writer", name, rootFS), f.Close())
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	return f.Close()
+}
+
+// mkdirAll creates the directory and all of its missing parents.
+func mkdirAll(rootFS OutputFS, dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+
+	err := mkdirAll(rootFS, path.Dir(dir))
+	if err != nil {
+		return err
+	}
+
+	err = rootFS.Mkdir(dir)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst, creating all missing parent directories of dst.
+func copyFile(rootFS OutputFS, src string, dst string) error {
+	contents, err := fs.ReadFile(rootFS, src)
+	if err != nil {
+		return err
+	}
+
+	err = mkdirAll(rootFS, path.Dir(dst))
+	if err != nil {
+		return err
+	}
+
+	return writeFile(rootFS, dst, contents)
+}
+
+// countingWriter counts the bytes written to w. If check is set, it is called with the total number of bytes
+// before every write and the write is rejected if it returns an error.
+type countingWriter struct {
+	w     io.Writer
+	n     int64
+	check func(n int64) error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.check != nil {
+		err := cw.check(cw.n + int64(len(p)))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,249 @@
+package drydock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing/fstest"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ErrDrift is returned by [Generator.Verify] when the output differs from the generated files.
+var ErrDrift = errors.New("output differs from generated files")
+
+// DriftReport describes the differences between the generated files and the output, see [Generator.Verify].
+type DriftReport struct {
+	// Missing are generated files that don't exist in the output.
+	Missing []string
+	// Changed are files whose contents in the output differ from the generated contents.
+	Changed []FileDrift
+	// Extra are files in generated directories that are not generated, excluding files matched by [WithKeep]
+	// or the [KeepFile]. Generated directories are those created by drydock according to the manifest, see
+	// [WithPrune], or, without a manifest, those of a [Directory]. Parents of modified files, see [ModifyFile],
+	// the output root and `.drydock` are never considered generated directories.
+	Extra []string
+}
+
+// FileDrift is a file whose contents differ from the generated contents.
+type FileDrift struct {
+	Path string
+	// Diff is a unified diff from the contents of the output to the generated contents.
+	Diff string
+}
+
+// HasDrift reports whether any differences were found.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Missing) != 0 || len(r.Changed) != 0 || len(r.Extra) != 0
+}
+
+func (r *DriftReport) String() string {
+	var b strings.Builder
+
+	for _, file := range r.Missing {
+		fmt.Fprintf(&b, "missing: %s\n", file)
+	}
+
+	for _, file := range r.Extra {
+		fmt.Fprintf(&b, "extra: %s\n", file)
+	}
+
+	for _, file := range r.Changed {
+		fmt.Fprintf(&b, "changed: %s\n%s", file.Path, file.Diff)
+	}
+
+	return b.String()
+}
+
+// Verify renders the files added using [Generator.Add] followed by files in memory and compares them to the output,
+// without writing anything, like `go generate && git diff --exit-code`. If the output differs, the returned error wraps
+// [ErrDrift]. Files with the [ConflictSkip] or [ConflictMerge] policy are only reported if they are missing.
+// Modifications, see [ModifyFile], are reported if applying them would change the file.
+func (g *Generator) Verify(ctx context.Context, files ...File) (*DriftReport, error) {
+	r, files := g.newRun(files)
+	r.tmptfs = &MapFSOutputFS{MapFS: fstest.MapFS{}, baseDir: "."}
+
+	for _, f := range files {
+		err := r.generate(ctx, "", f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report, err := r.drift()
+	if err != nil {
+		return nil, err
+	}
+
+	if report.HasDrift() {
+		return report, fmt.Errorf("%w: %d missing, %d changed, %d extra files", ErrDrift, len(report.Missing), len(report.Changed), len(report.Extra))
+	}
+
+	return report, nil
+}
+
+// TestingT is the subset of [testing.TB] used by [AssertNoDrift].
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertNoDrift fails the test if the output of g differs from the generated files, see [Generator.Verify].
+// It is meant to be used in a test next to the generated code:
+//
+//	func TestGeneratedCodeIsUpToDate(t *testing.T) {
+//		drydock.AssertNoDrift(t, newGenerator(drydock.NewOSOutputFS(".")))
+//	}
+func AssertNoDrift(t TestingT, g *Generator, files ...File) {
+	t.Helper()
+
+	report, err := g.Verify(context.Background(), files...)
+	if err != nil {
+		if report != nil {
+			t.Errorf("%v, regenerate the files:\n%s", err, report)
+			return
+		}
+
+		t.Errorf("error verifying generated files: %v", err)
+	}
+}
+
+func (r *run) drift() (*DriftReport, error) {
+	report := &DriftReport{Missing: []string{}, Changed: []FileDrift{}, Extra: []string{}}
+	staged := make(map[string]bool, len(r.tmpfiles)+len(r.tmpmodified))
+
+	for _, file := range append(slices.Clone(r.tmpfiles), r.tmpmodified...) {
+		staged[file] = true
+
+		existing, err := fs.ReadFile(r.output, file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				report.Missing = append(report.Missing, file)
+				continue
+			}
+
+			return nil, fmt.Errorf("error reading file '%s': %w", file, err)
+		}
+
+		if policy := r.conflictSetting(file).policy; policy == ConflictSkip || policy == ConflictMerge {
+			continue
+		}
+
+		generated, err := fs.ReadFile(r.tmptfs, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading generated file '%s': %w", file, err)
+		}
+
+		if bytes.Equal(existing, generated) {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(existing),
+			B:        splitLines(generated),
+			FromFile: "a/" + file,
+			ToFile:   "b/" + file,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error diffing file '%s': %w", file, err)
+		}
+
+		report.Changed = append(report.Changed, FileDrift{Path: file, Diff: diff})
+	}
+
+	extra, err := r.extraFiles(staged)
+	if err != nil {
+		return nil, err
+	}
+
+	for file := range extra {
+		report.Extra = append(report.Extra, file)
+	}
+
+	slices.Sort(report.Missing)
+	slices.Sort(report.Extra)
+	slices.SortFunc(report.Changed, func(a, b FileDrift) int { return strings.Compare(a.Path, b.Path) })
+
+	return report, nil
+}
+
+// extraFiles returns the files in the generated dirs of the output that are not staged.
+func (r *run) extraFiles(staged map[string]bool) (map[string]bool, error) {
+	keep, err := r.loadKeepFile()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := readManifest(r.output)
+	if err != nil {
+		return nil, err
+	}
+
+	extra := map[string]bool{}
+	for dir := range r.tmpdirs {
+		if !r.isGeneratedDir(dir, m) {
+			continue
+		}
+
+		err := fs.WalkDir(r.output, dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+
+				return err
+			}
+
+			if keep.match(p, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+
+				return nil
+			}
+
+			if !d.IsDir() && !staged[p] {
+				extra[p] = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading dir '%s': %w", dir, err)
+		}
+	}
+
+	return extra, nil
+}
+
+// isGeneratedDir reports whether the files in dir are expected to be generated. The root and `.drydock` contain
+// files of the user and of drydock itself. If there is a manifest, only the dirs listed in it were created by drydock,
+// otherwise only the dirs of a [Directory] are, not the parents staged for other files.
+func (r *run) isGeneratedDir(dir string, m *manifest) bool {
+	if dir == "." || dir == "" || dir == ".drydock" || strings.HasPrefix(dir, ".drydock/") {
+		return false
+	}
+
+	if len(m.Files) == 0 && len(m.Dirs) == 0 {
+		return r.entryDirs[dir]
+	}
+
+	return slices.Contains(m.Dirs, dir)
+}
+
+// splitLines splits b after each newline. A missing newline at the end is added, so the diff stays readable.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+
+	lines[len(lines)-1] += "\n"
+
+	return lines
+}
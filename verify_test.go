@@ -0,0 +1,148 @@
+package drydock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_Verify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	files := []File{
+		PlainFile("README.md", "# drydock\n"),
+		Dir("cmd", PlainFile("main.go", "package main\n\nfunc main() {}\n")),
+		OnConflict(PlainFile("LICENSE", "license"), ConflictSkip),
+		ModifyFile("config.ini", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(contents)
+			return err
+		}),
+	}
+
+	t.Run("No Drift", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"config.ini": &fstest.MapFile{Data: []byte("foo = bar")},
+		}, baseDir: "."}
+
+		g := NewGenerator(tmpfs)
+		require.NoError(t, g.Generate(ctx, files...))
+
+		tmpfs.MapFS["LICENSE"] = &fstest.MapFile{Data: []byte("edited license")}
+		tmpfs.MapFS["cmd/.gitignore"] = &fstest.MapFile{Data: []byte("bin")}
+
+		report, err := NewGenerator(tmpfs, WithKeep(".gitignore")).Verify(ctx, files...)
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift())
+
+		AssertNoDrift(t, NewGenerator(tmpfs, WithKeep(".gitignore")).Add(files...))
+	})
+
+	t.Run("Drift", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"cmd/main.go":    &fstest.MapFile{Data: []byte("package main\n\nfunc main() { panic(1) }\n")},
+			"cmd/old.go":     &fstest.MapFile{Data: []byte("package main")},
+			"cmd/sub/sub.go": &fstest.MapFile{Data: []byte("package sub")},
+			"config.ini":     &fstest.MapFile{Data: []byte("foo = bar")},
+			"unrelated.txt":  &fstest.MapFile{Data: []byte("not generated")},
+			"LICENSE":        &fstest.MapFile{Data: []byte("license")},
+		}, baseDir: "."}
+
+		before := fmt.Sprint(tmpfs.MapFS)
+
+		report, err := NewGenerator(tmpfs).Verify(ctx, files...)
+		require.ErrorIs(t, err, ErrDrift)
+		assert.EqualError(t, err, "output differs from generated files: 1 missing, 1 changed, 2 extra files")
+
+		assert.Equal(t, []string{"README.md"}, report.Missing)
+		assert.Equal(t, []string{"cmd/old.go", "cmd/sub/sub.go"}, report.Extra)
+		assert.Equal(t, []FileDrift{{Path: "cmd/main.go", Diff: `--- a/cmd/main.go
++++ b/cmd/main.go
+@@ -1,3 +1,3 @@
+ package main
+ 
+-func main() { panic(1) }
++func main() {}
+`}}, report.Changed)
+
+		assert.Equal(t, before, fmt.Sprint(tmpfs.MapFS))
+
+		mockT := &mockTestingT{}
+		AssertNoDrift(mockT, NewGenerator(tmpfs), files...)
+		assert.Contains(t, mockT.errors, "missing: README.md\n")
+	})
+}
+
+func TestGenerator_Verify_Extra(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	t.Run("Root", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			".git/HEAD":       &fstest.MapFile{Data: []byte("ref: refs/heads/main")},
+			".drydock/lock":   &fstest.MapFile{Data: []byte("1\nhost\n")},
+			"notes.txt":       &fstest.MapFile{Data: []byte("user file")},
+			"README.md":       &fstest.MapFile{Data: []byte("# drydock\n")},
+			"cmd/main.go":     &fstest.MapFile{Data: []byte("package main\n")},
+			"cmd/old.go":      &fstest.MapFile{Data: []byte("package main\n")},
+			"cmd/.gitignore":  &fstest.MapFile{Data: []byte("bin")},
+			"vendor/x/x.go":   &fstest.MapFile{Data: []byte("package x\n")},
+			"vendor/x/y/y.go": &fstest.MapFile{Data: []byte("package y\n")},
+		}, baseDir: "."}
+
+		report, err := NewGenerator(tmpfs, WithKeep(".gitignore")).Verify(ctx, Dir(".",
+			PlainFile("README.md", "# drydock\n"),
+			Dir("cmd", PlainFile("main.go", "package main\n")),
+		))
+		require.ErrorIs(t, err, ErrDrift)
+		assert.Equal(t, []string{"cmd/old.go"}, report.Extra)
+	})
+
+	t.Run("Manifest", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"pkg/user.go": &fstest.MapFile{Data: []byte("package pkg\n")},
+		}, baseDir: "."}
+
+		files := []File{
+			Dir("pkg", PlainFile("pkg.go", "package pkg\n")),
+			Dir("gen", PlainFile("gen.go", "package gen\n")),
+		}
+
+		require.NoError(t, NewGenerator(tmpfs, WithPrune(true)).Generate(ctx, files...))
+
+		tmpfs.MapFS["gen/old.go"] = &fstest.MapFile{Data: []byte("package gen\n")}
+
+		report, err := NewGenerator(tmpfs, WithPrune(true)).Verify(ctx, files...)
+		require.ErrorIs(t, err, ErrDrift)
+		assert.Equal(t, []string{"gen/old.go"}, report.Extra)
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		tmpfs := &MapFSOutputFS{MapFS: fstest.MapFS{
+			"pkg/a.go": &fstest.MapFile{Data: []byte("package pkg\n")},
+			"pkg/b.go": &fstest.MapFile{Data: []byte("package pkg\n")},
+		}, baseDir: "."}
+
+		report, err := NewGenerator(tmpfs).Verify(ctx, ModifyFile("pkg/a.go", func(contents []byte, w io.Writer) error {
+			_, err := w.Write(contents)
+			return err
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, report.Extra)
+	})
+}
+
+type mockTestingT struct {
+	errors string
+}
+
+func (*mockTestingT) Helper() {}
+
+func (t *mockTestingT) Errorf(format string, args ...any) {
+	t.errors += fmt.Sprintf(format, args...)
+}